@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	bk "github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// Image used to inspect and clear cache directories
+const cacheToolsImage = "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+
+// CacheTypes are the types of cache records, by name
+var CacheTypes = map[string][]bk.UsageRecordType{
+	// `core.#CacheDir` mounts
+	"cachemount": {bk.UsageRecordTypeCacheMount},
+	// Filesystem layers produced by tasks
+	"layer": {bk.UsageRecordTypeRegular},
+	// Client directories and git repositories
+	"source":   {bk.UsageRecordTypeLocalSource, bk.UsageRecordTypeGitCheckout},
+	"internal": {bk.UsageRecordTypeInternal, bk.UsageRecordTypeFrontend},
+}
+
+// CacheFilter selects cache records
+type CacheFilter struct {
+	// Types of records (see CacheTypes). All types if empty.
+	Types []string
+
+	// Only select records which weren't used for this long
+	OlderThan time.Duration
+}
+
+// filters returns the buildkit filters of the record types
+func (f CacheFilter) filters() ([]string, error) {
+	filters := []string{}
+	for _, name := range f.Types {
+		types, ok := CacheTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cache type %q", name)
+		}
+		for _, t := range types {
+			filters = append(filters, "type=="+string(t))
+		}
+	}
+	return filters, nil
+}
+
+// TypeName returns the name of the type of a cache record
+func TypeName(t bk.UsageRecordType) string {
+	for name, types := range CacheTypes {
+		for _, typ := range types {
+			if typ == t {
+				return name
+			}
+		}
+	}
+	return string(t)
+}
+
+// DiskUsage lists the cache records of the buildkit daemon
+func (c *Client) DiskUsage(ctx context.Context, filter CacheFilter) ([]*bk.UsageInfo, error) {
+	filters, err := filter.filters()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := c.c.DiskUsage(ctx, bk.WithFilter(filters))
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.OlderThan == 0 {
+		return records, nil
+	}
+
+	selected := []*bk.UsageInfo{}
+	for _, r := range records {
+		lastUsed := r.CreatedAt
+		if r.LastUsedAt != nil {
+			lastUsed = *r.LastUsedAt
+		}
+		if time.Since(lastUsed) >= filter.OlderThan {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
+
+// Prune removes the cache records which aren't in use. If keepStorage is
+// positive, the most recently used records are kept up to this size.
+func (c *Client) Prune(ctx context.Context, filter CacheFilter, keepStorage int64) ([]bk.UsageInfo, error) {
+	filters, err := filter.filters()
+	if err != nil {
+		return nil, err
+	}
+
+	// Also prune cache mounts, which buildkit retains by default
+	opts := []bk.PruneOption{
+		bk.PruneAll,
+		bk.WithFilter(filters),
+		bk.WithKeepOpt(filter.OlderThan, keepStorage),
+	}
+
+	ch := make(chan bk.UsageInfo)
+	pruned := []bk.UsageInfo{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for r := range ch {
+			pruned = append(pruned, r)
+		}
+	}()
+
+	err = c.c.Prune(ctx, ch, opts...)
+	close(ch)
+	<-done
+
+	return pruned, err
+}
+
+// CacheDirUsage returns the size of `core.#CacheDir` mounts, by id
+func (c *Client) CacheDirUsage(ctx context.Context, ids []string) (map[string]int64, error) {
+	usage := make(map[string]int64, len(ids))
+
+	script := `for i in /cache/*; do echo "$(du -sk "$i" | cut -f1) $(basename "$i")"; done > /out/usage`
+	err := c.runCacheDirs(ctx, ids, script, func(ctx context.Context, out bkgw.Reference) error {
+		data, err := out.ReadFile(ctx, bkgw.ReadRequest{Filename: "usage"})
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 2 {
+				continue
+			}
+			i, err := strconv.Atoi(fields[1])
+			if err != nil || i >= len(ids) {
+				continue
+			}
+			size, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse cache usage: %w", err)
+			}
+			usage[ids[i]] = size * 1024
+		}
+		return scanner.Err()
+	})
+
+	return usage, err
+}
+
+// ClearCacheDirs removes the contents of `core.#CacheDir` mounts, by id
+func (c *Client) ClearCacheDirs(ctx context.Context, ids []string) error {
+	script := `find /cache -mindepth 2 -maxdepth 2 -exec rm -rf {} + && touch /out/done`
+	return c.runCacheDirs(ctx, ids, script, func(ctx context.Context, out bkgw.Reference) error {
+		_, err := out.StatFile(ctx, bkgw.StatRequest{Path: "done"})
+		return err
+	})
+}
+
+// runCacheDirs runs a script with the cache directories mounted in
+// /cache/<index of id>, and an empty /out directory passed to fn
+func (c *Client) runCacheDirs(ctx context.Context, ids []string, script string, fn func(context.Context, bkgw.Reference) error) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	opts := []llb.RunOption{
+		llb.Args([]string{"sh", "-c", script}),
+		llb.IgnoreCache,
+		llb.WithCustomName("inspecting cache directories"),
+	}
+	for i, id := range ids {
+		opts = append(opts, llb.AddMount(
+			path.Join("/cache", strconv.Itoa(i)),
+			llb.Scratch(),
+			llb.AsPersistentCacheDir(id, llb.CacheMountShared),
+		))
+	}
+
+	out := llb.Image(cacheToolsImage).
+		Run(opts...).
+		AddMount("/out", llb.Scratch())
+
+	// Same default platform as plans
+	def, err := out.Marshal(ctx, llb.LinuxAmd64)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.c.Build(ctx, bk.SolveOpt{}, "", func(ctx context.Context, gw bkgw.Client) (*bkgw.Result, error) {
+		res, err := gw.Solve(ctx, bkgw.SolveRequest{
+			Definition: def.ToPB(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		ref, err := res.SingleRef()
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(ctx, ref); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}, nil)
+
+	return err
+}
@@ -40,8 +40,15 @@ type Client struct {
 type Config struct {
 	NoCache bool
 
+	// EngineDriver runs the buildkit daemon when no host is configured
+	EngineDriver string
+	EngineConfig *buildkitd.Config
+
 	CacheExports []bk.CacheOptionsEntry
 	CacheImports []bk.CacheOptionsEntry
+
+	// DumpLLB is a directory where solved definitions are written
+	DumpLLB string
 }
 
 func New(ctx context.Context, host string, cfg Config) (*Client, error) {
@@ -49,7 +56,7 @@ func New(ctx context.Context, host string, cfg Config) (*Client, error) {
 		host = os.Getenv("BUILDKIT_HOST")
 	}
 	if host == "" {
-		h, err := buildkitd.Start(ctx)
+		h, err := buildkitd.Start(ctx, cfg.EngineDriver, cfg.EngineConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -72,6 +79,9 @@ func New(ctx context.Context, host string, cfg Config) (*Client, error) {
 	}, nil
 }
 
+// Number of lines of output of a failed command included in its error
+const errorLogLines = 20
+
 type DoFunc func(context.Context, solver.Solver) error
 
 // FIXME: return completed *Route, instead of *compiler.Value
@@ -134,9 +144,13 @@ func (c *Client) buildfn(ctx context.Context, pctx *plancontext.Context, fn DoFu
 		Interface("attrs", opts.FrontendAttrs).
 		Msg("spawning buildkit job")
 
+	// Keep the end of each command's output, for error reports
+	logs := solver.NewVertexLogs(errorLogLines)
+
 	// Catch output from events
 	catchOutput := func(inCh chan *bk.SolveStatus) {
 		for e := range inCh {
+			logs.Add(e)
 			ch <- e
 		}
 		wg.Done()
@@ -159,8 +173,11 @@ func (c *Client) buildfn(ctx context.Context, pctx *plancontext.Context, fn DoFu
 			Control: c.c,
 			Gateway: gw,
 			Events:  eventsCh,
+			Context: pctx,
 			Auth:    auth,
 			NoCache: c.cfg.NoCache,
+			Logs:    logs,
+			DumpDir: c.cfg.DumpLLB,
 		})
 
 		// Close events channel
@@ -214,14 +231,7 @@ func (c *Client) logSolveStatus(ctx context.Context, pctx *plancontext.Context,
 
 	// Just like sprintf, but redacts secrets automatically
 	secureSprintf := func(format string, a ...interface{}) string {
-		// Load a fresh copy of secrets (since they can be dynamically added).
-		secrets := pctx.Secrets.List()
-
-		s := fmt.Sprintf(format, a...)
-		for _, secret := range secrets {
-			s = strings.ReplaceAll(s, secret.PlainText(), "***")
-		}
-		return s
+		return pctx.Secrets.Redact(fmt.Sprintf(format, a...))
 	}
 
 	return progressui.PrintSolveStatus(ctx, ch,
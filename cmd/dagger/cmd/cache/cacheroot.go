@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	bk "github.com/moby/buildkit/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/client"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and prune the build cache",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+}
+
+// record is the json representation of a cache record
+type record struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	Size        int64      `json:"size"`
+	InUse       bool       `json:"inUse"`
+	Shared      bool       `json:"shared"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	UsageCount  int        `json:"usageCount"`
+	Description string     `json:"description,omitempty"`
+}
+
+// cacheDir is the json representation of a `core.#CacheDir`
+type cacheDir struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+// cacheFilter returns the filter set with --type and --older-than
+func cacheFilter() client.CacheFilter {
+	return client.CacheFilter{
+		Types:     viper.GetStringSlice("type"),
+		OlderThan: viper.GetDuration("older-than"),
+	}
+}
+
+func printRecords(w io.Writer, records []bk.UsageInfo, format string) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Size > records[j].Size
+	})
+
+	switch format {
+	case "json":
+		out := make([]record, 0, len(records))
+		for _, r := range records {
+			out = append(out, record{
+				ID:          r.ID,
+				Type:        client.TypeName(r.RecordType),
+				Size:        r.Size,
+				InUse:       r.InUse,
+				Shared:      r.Shared,
+				CreatedAt:   r.CreatedAt,
+				LastUsedAt:  r.LastUsedAt,
+				UsageCount:  r.UsageCount,
+				Description: r.Description,
+			})
+		}
+		return printJSON(w, out)
+	case "text":
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+
+	var total int64
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tSIZE\tLAST USED\tDESCRIPTION")
+	for _, r := range records {
+		lastUsed := "never"
+		if r.LastUsedAt != nil {
+			lastUsed = units.HumanDuration(time.Since(*r.LastUsedAt)) + " ago"
+		}
+		id := r.ID
+		if r.InUse {
+			id += "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", id, client.TypeName(r.RecordType), units.HumanSize(float64(r.Size)), lastUsed, truncate(r.Description, 60))
+		total += r.Size
+	}
+	fmt.Fprintf(tw, "Total:\t\t%s\t\t\n", units.HumanSize(float64(total)))
+	return tw.Flush()
+}
+
+func printCacheDirs(w io.Writer, ids []string, usage map[string]int64, format string) error {
+	dirs := make([]cacheDir, 0, len(ids))
+	for _, id := range ids {
+		dirs = append(dirs, cacheDir{ID: id, Size: usage[id]})
+	}
+
+	switch format {
+	case "json":
+		return printJSON(w, dirs)
+	case "text":
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CACHE ID\tSIZE")
+	for _, d := range dirs {
+		fmt.Fprintf(tw, "%s\t%s\n", d.ID, units.HumanSize(float64(d.Size)))
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func init() {
+	names := make([]string, 0, len(client.CacheTypes))
+	for name := range client.CacheTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	Cmd.PersistentFlags().StringSlice("type", []string{}, fmt.Sprintf("Only select records of these types (%s)", strings.Join(names, ", ")))
+	Cmd.PersistentFlags().Duration("older-than", 0, "Only select records unused for this long (e.g. 24h)")
+	Cmd.PersistentFlags().StringArray("id", []string{}, "Only select the core.#CacheDir mounts with this id")
+	Cmd.PersistentFlags().String("format", "text", "Output format (text, json)")
+
+	if err := viper.BindPFlags(Cmd.PersistentFlags()); err != nil {
+		panic(err)
+	}
+
+	Cmd.AddCommand(
+		duCmd,
+		pruneCmd,
+	)
+}
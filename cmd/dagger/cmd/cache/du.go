@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"os"
+
+	bk "github.com/moby/buildkit/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/cmd/common"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+)
+
+var duCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Print the disk usage of the build cache",
+	Long: `Print the disk usage of the build cache.
+
+Records marked with * are in use. With --id, print the size of core.#CacheDir
+mounts instead.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+		ctx := lg.WithContext(cmd.Context())
+
+		cl := common.NewClient(ctx, nil)
+		format := viper.GetString("format")
+
+		if ids := viper.GetStringSlice("id"); len(ids) > 0 {
+			usage, err := cl.CacheDirUsage(ctx, ids)
+			if err != nil {
+				lg.Fatal().Err(err).Msg("failed to get cache usage")
+			}
+			if err := printCacheDirs(os.Stdout, ids, usage, format); err != nil {
+				lg.Fatal().Err(err).Msg("failed to print cache usage")
+			}
+			return
+		}
+
+		records, err := cl.DiskUsage(ctx, cacheFilter())
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to get cache usage")
+		}
+
+		list := make([]bk.UsageInfo, 0, len(records))
+		for _, r := range records {
+			list = append(list, *r)
+		}
+		if err := printRecords(os.Stdout, list, format); err != nil {
+			lg.Fatal().Err(err).Msg("failed to print cache usage")
+		}
+	},
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"os"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/cmd/common"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unused records from the build cache",
+	Long: `Remove unused records from the build cache.
+
+Without filters, the whole cache is pruned, except the records in use.
+With --id, the contents of core.#CacheDir mounts are removed instead.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+		ctx := lg.WithContext(cmd.Context())
+
+		var keepStorage int64
+		if keep := viper.GetString("keep-storage"); keep != "" {
+			var err error
+			keepStorage, err = units.RAMInBytes(keep)
+			if err != nil {
+				lg.Fatal().Err(err).Msg("invalid --keep-storage")
+			}
+		}
+
+		cl := common.NewClient(ctx, nil)
+		filter := cacheFilter()
+
+		ids := viper.GetStringSlice("id")
+		if len(ids) > 0 {
+			if err := cl.ClearCacheDirs(ctx, ids); err != nil {
+				lg.Fatal().Err(err).Msg("failed to clear cache directories")
+			}
+			lg.Info().Strs("id", ids).Msg("cleared cache directories")
+
+			// Only prune other records when asked to
+			if len(filter.Types) == 0 && filter.OlderThan == 0 && keepStorage == 0 {
+				return
+			}
+		}
+
+		pruned, err := cl.Prune(ctx, filter, keepStorage)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to prune cache")
+		}
+		if err := printRecords(os.Stdout, pruned, viper.GetString("format")); err != nil {
+			lg.Fatal().Err(err).Msg("failed to print pruned records")
+		}
+	},
+}
+
+func init() {
+	pruneCmd.Flags().String("keep-storage", "", "Keep the most recently used records up to this size (e.g. 10GB)")
+
+	if err := viper.BindPFlags(pruneCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
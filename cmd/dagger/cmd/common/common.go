@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"cuelang.org/go/cue"
@@ -11,7 +12,10 @@ import (
 	"github.com/spf13/viper"
 	"go.dagger.io/dagger/client"
 	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/pkg"
+	"go.dagger.io/dagger/plan"
 	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/util/buildkitd"
 )
 
 // FormatValue returns the String representation of the cue value
@@ -82,8 +86,9 @@ func ValueDocOneLine(val *compiler.Value) string {
 	return strings.Join(docs, " ")
 }
 
-// NewClient creates a new client
-func NewClient(ctx context.Context) *client.Client {
+// NewClient creates a new client. The --cache-to and --cache-from flags
+// override the cache configuration of the plan, if any.
+func NewClient(ctx context.Context, cache *plan.CacheConfig) *client.Client {
 	lg := log.Ctx(ctx)
 
 	cacheExports, err := buildflags.ParseCacheEntry(viper.GetStringSlice("cache-to"))
@@ -95,10 +100,22 @@ func NewClient(ctx context.Context) *client.Client {
 		lg.Fatal().Err(err).Msg("unable to parse --cache-from options")
 	}
 
+	if cache != nil {
+		if len(cacheExports) == 0 {
+			cacheExports = cache.Exports
+		}
+		if len(cacheImports) == 0 {
+			cacheImports = cache.Imports
+		}
+	}
+
 	cl, err := client.New(ctx, "", client.Config{
 		CacheExports: cacheExports,
 		CacheImports: cacheImports,
 		NoCache:      viper.GetBool("no-cache"),
+		EngineDriver: viper.GetString("engine-driver"),
+		EngineConfig: EngineConfig(ctx),
+		DumpLLB:      viper.GetString("dump-llb"),
 	})
 	if err != nil {
 		lg.Fatal().Err(err).Msg("unable to create client")
@@ -106,3 +123,23 @@ func NewClient(ctx context.Context) *client.Client {
 
 	return cl
 }
+
+// EngineConfig loads the config of the buildkit daemon from the user config,
+// the project config and --engine-config, in order
+func EngineConfig(ctx context.Context) *buildkitd.Config {
+	lg := log.Ctx(ctx)
+
+	paths := []string{"~/.config/dagger/engine.yaml"}
+	if project, found := pkg.GetCueModParent(); found {
+		paths = append(paths, filepath.Join(project, "dagger.engine.yaml"))
+	}
+	if path := viper.GetString("engine-config"); path != "" {
+		paths = append(paths, path)
+	}
+
+	cfg, err := buildkitd.LoadConfig(paths...)
+	if err != nil {
+		lg.Fatal().Err(err).Msg("unable to load engine config")
+	}
+	return cfg
+}
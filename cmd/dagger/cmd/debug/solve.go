@@ -0,0 +1,100 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/platforms"
+	bk "github.com/moby/buildkit/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/cmd/common"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/solver"
+)
+
+var solveCmd = &cobra.Command{
+	Use:   "solve [OPTIONS] FILE",
+	Short: "Solve a definition dumped by dagger do --dump-llb",
+	Long: `Solve a definition dumped by dagger do --dump-llb, without the plan.
+
+The client directories read by the definition must still exist. Secrets and
+sockets aren't dumped, definitions using them can't be replayed.`,
+	Args: cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+		ctx := lg.WithContext(cmd.Context())
+
+		dump, err := solver.ReadDump(args[0])
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to read definition")
+		}
+		def, err := dump.PB()
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to read definition")
+		}
+		platform, err := platforms.Parse(dump.Platform)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to read definition")
+		}
+
+		pctx := plancontext.New()
+		for name := range dump.LocalDirs {
+			pctx.LocalDirs.Add(name)
+		}
+
+		lg.Info().
+			Str("task", dump.Task).
+			Str("platform", dump.Platform).
+			Str("digest", dump.Digest.String()).
+			Msg("solving definition")
+
+		output := viper.GetString("output")
+
+		cl := common.NewClient(ctx, nil)
+		err = cl.Do(ctx, pctx, func(ctx context.Context, s solver.Solver) error {
+			ref, err := s.SolveDefinition(ctx, def)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				return nil
+			}
+
+			if ref == nil {
+				return fmt.Errorf("definition has no output")
+			}
+			st, err := ref.ToState()
+			if err != nil {
+				return err
+			}
+			_, err = s.Export(ctx, st, nil, bk.ExportEntry{
+				Type:      bk.ExporterLocal,
+				OutputDir: output,
+			}, platform)
+			return err
+		})
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to solve definition")
+		}
+
+		lg.Info().Msg("definition solved")
+	},
+}
+
+func init() {
+	solveCmd.Flags().StringP("output", "o", "", "Export the resulting filesystem to `DIR`")
+	solveCmd.Flags().Bool("no-cache", false, "Disable caching")
+
+	if err := viper.BindPFlags(solveCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
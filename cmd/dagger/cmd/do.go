@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,18 +11,20 @@ import (
 	"text/tabwriter"
 
 	"cuelang.org/go/cue"
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.dagger.io/dagger/cmd/dagger/cmd/common"
 	"go.dagger.io/dagger/cmd/dagger/logger"
 	"go.dagger.io/dagger/plan"
+	"go.dagger.io/dagger/plan/task"
 	"go.dagger.io/dagger/solver"
 	"go.dagger.io/dagger/telemetry"
 	"golang.org/x/term"
 )
 
 var doCmd = &cobra.Command{
-	Use:   "do [OPTIONS] ACTION [SUBACTION...]",
+	Use:   "do [OPTIONS] [ACTION [SUBACTION...]] [--target ACTION...]",
 	Short: "Execute a dagger action.",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		// Fix Viper bug for duplicate flags:
@@ -31,17 +34,26 @@ var doCmd = &cobra.Command{
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) < 1 {
+		if len(args) < 1 && len(viper.GetStringSlice("target")) < 1 {
 			doHelpCmd(cmd, nil)
 			return
 		}
 
 		var (
 			lg  = logger.New()
+			out = logger.Output()
 			tty *logger.TTYOutput
 			err error
 		)
 
+		// Exit once the deferred cleanup is done
+		exitCode := 0
+		defer func() {
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+		}()
+
 		if f := viper.GetString("log-format"); f == "tty" || f == "auto" && term.IsTerminal(int(os.Stdout.Fd())) {
 			tty, err = logger.NewTTYOutput(os.Stderr)
 			if err != nil {
@@ -51,32 +63,70 @@ var doCmd = &cobra.Command{
 			defer tty.Stop()
 
 			lg = lg.Output(tty)
+			out = tty
 		}
 
-		ctx := lg.WithContext(cmd.Context())
-		cl := common.NewClient(ctx)
-
 		p, err := loadPlan()
 		if err != nil {
 			lg.Fatal().Err(err).Msg("failed to load plan")
 		}
-		target := getTargetPath(args)
+		targets, err := getTargetPaths(args)
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to parse targets")
+		}
+
+		// Remove the temporary directories of the tasks, even when interrupted
+		defer p.Context().TempDirs.Clean()
 
+		// Never print the plain text of secrets, whatever the log output
+		lg = lg.Output(&logger.RedactedOutput{
+			Out:      out,
+			Redactor: p.Context().Secrets,
+		})
+
+		ctx := lg.WithContext(cmd.Context())
+		if tty != nil {
+			ctx = task.WithPauser(ctx, tty)
+		}
+		cache := p.Cache()
+		cl := common.NewClient(ctx, &cache)
+
+		actions := []string{}
+		for _, target := range targets {
+			actions = append(actions, target.String())
+		}
 		doneCh := common.TrackCommand(ctx, cmd, &telemetry.Property{
 			Name:  "action",
-			Value: target.String(),
+			Value: strings.Join(actions, ","),
 		})
 
+		var doErr error
 		err = cl.Do(ctx, p.Context(), func(ctx context.Context, s solver.Solver) error {
-			return p.Do(ctx, target, s)
+			doErr = p.Do(ctx, targets, s)
+			return doErr
 		})
 
 		<-doneCh
 
-		p.Context().TempDirs.Clean()
+		if format := viper.GetString("secrets-report"); format != "" {
+			if err := printSecretsReport(os.Stdout, p.Context().Secrets.Report(), format); err != nil {
+				lg.Error().Err(err).Msg("failed to print secrets report")
+			}
+		}
 
-		if err != nil {
-			lg.Fatal().Err(err).Msg("failed to execute plan")
+		switch {
+		case ctx.Err() != nil:
+			lg.Error().Msg("interrupted")
+			exitCode = exitInterrupted
+		case err != nil:
+			// Report the failed task with its details, which buildkit can't relay
+			var taskErr *task.Error
+			if errors.As(doErr, &taskErr) {
+				err = taskErr
+			}
+			// Not Fatal(), which would exit before the deferred cleanup
+			lg.WithLevel(zerolog.FatalLevel).Err(err).Msg("failed to execute plan")
+			exitCode = 1
 		}
 	},
 }
@@ -97,8 +147,11 @@ func loadPlan() (*plan.Plan, error) {
 	}
 
 	return plan.Load(context.Background(), plan.Config{
-		Args: []string{planPath},
-		With: viper.GetStringSlice("with"),
+		Args:         []string{planPath},
+		With:         viper.GetStringSlice("with"),
+		Memoize:      viper.GetBool("memoize"),
+		ChangedSince: viper.GetString("changed-since"),
+		Parallel:     viper.GetInt("parallel"),
 	})
 }
 
@@ -110,6 +163,25 @@ func getTargetPath(args []string) cue.Path {
 	return cue.MakePath(selectors...)
 }
 
+// getTargetPaths returns the actions to run: the action given as arguments, if
+// any, and the action of each --target
+func getTargetPaths(args []string) ([]cue.Path, error) {
+	targets := []cue.Path{}
+	if len(args) > 0 {
+		targets = append(targets, getTargetPath(args))
+	}
+
+	for _, target := range viper.GetStringSlice("target") {
+		path, err := plan.ParseActionPath(target)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, path)
+	}
+
+	return targets, nil
+}
+
 func doHelpCmd(cmd *cobra.Command, _ []string) {
 	lg := logger.New()
 
@@ -156,12 +228,20 @@ func printActions(w io.Writer, target cue.Path) error {
 func init() {
 	doCmd.Flags().StringArrayP("with", "w", []string{}, "")
 	doCmd.Flags().StringP("plan", "p", ".", "Path to plan (defaults to current directory)")
+	doCmd.Flags().StringArrayP("target", "t", []string{}, "Also run the action at `PATH` (eg. test.unit), in the same run")
 	doCmd.Flags().Bool("no-cache", false, "Disable caching")
 	doCmd.Flags().StringArray("cache-to", []string{},
 		"Cache export destinations (eg. user/app:cache, type=local,dest=path/to/dir)")
 	doCmd.Flags().StringArray("cache-from", []string{},
 		"External cache sources (eg. user/app:cache, type=local,src=path/to/dir)")
 
+	doCmd.Flags().Int("parallel", 0, "Run at most `N` tasks at once (defaults to the limit of the plan, if any)")
+	doCmd.Flags().String("changed-since", "", "Only run the actions reading client files changed since the git revision `REF`")
+	doCmd.Flags().Bool("memoize", false, "Skip pushes and client writes whose inputs didn't change since their last run")
+	doCmd.Flags().String("dump-llb", "", "Write the definitions solved by tasks to `DIR`, to replay them with dagger debug solve")
+	doCmd.Flags().String("secrets-report", "", "Print where secrets were sourced and injected after execution (text, json)")
+	doCmd.Flags().Lookup("secrets-report").NoOptDefVal = "text"
+
 	doCmd.SetHelpFunc(doHelpCmd)
 
 	if err := viper.BindPFlags(doCmd.Flags()); err != nil {
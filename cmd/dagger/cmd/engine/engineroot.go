@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/util/buildkitd"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "engine",
+	Short: "Manage the buildkit daemon used by dagger",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+}
+
+// printStatus prints the status of the buildkit daemon, as text or json
+func printStatus(w io.Writer, status *buildkitd.Status, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	case "text":
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+
+	state := "running"
+	switch {
+	case !status.Exists:
+		state = "not installed"
+	case !status.Running:
+		state = "stopped"
+	}
+
+	version := status.Version
+	if status.Version != status.ExpectedVersion {
+		version = fmt.Sprintf("%s (outdated, expected %s)", status.Version, status.ExpectedVersion)
+	}
+
+	config := status.ConfigHash
+	if config == "" {
+		config = "default"
+	}
+	if status.ConfigHash != status.ExpectedConfig {
+		config += " (changed, run `dagger engine upgrade` to apply)"
+	}
+
+	volume := "absent"
+	if status.VolumeExists {
+		volume = "present"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Driver:\t%s\n", status.Driver)
+	fmt.Fprintf(tw, "Host:\t%s\n", status.Host)
+	fmt.Fprintf(tw, "State:\t%s\n", state)
+	if status.Exists {
+		fmt.Fprintf(tw, "Version:\t%s\n", version)
+		fmt.Fprintf(tw, "Host network:\t%t\n", status.HostNetwork)
+		fmt.Fprintf(tw, "Config:\t%s\n", config)
+	}
+	fmt.Fprintf(tw, "Volume:\t%s (%s)\n", status.Volume, volume)
+	return tw.Flush()
+}
+
+func init() {
+	Cmd.PersistentFlags().String("format", "text", "Output format (text, json)")
+
+	if err := viper.BindPFlags(Cmd.PersistentFlags()); err != nil {
+		panic(err)
+	}
+
+	Cmd.AddCommand(
+		statusCmd,
+		startCmd,
+		stopCmd,
+		logsCmd,
+		upgradeCmd,
+		rmCmd,
+	)
+}
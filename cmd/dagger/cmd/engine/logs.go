@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+	"go.dagger.io/dagger/util/buildkitd"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Print the logs of the buildkit daemon",
+	Args:  cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+		ctx := lg.WithContext(cmd.Context())
+
+		d, err := buildkitd.GetDriver(ctx, viper.GetString("engine-driver"))
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to get engine driver")
+		}
+
+		if err := buildkitd.Logs(ctx, d, os.Stdout, viper.GetBool("follow"), viper.GetInt("tail")); err != nil {
+			lg.Fatal().Err(err).Msg("failed to get buildkit logs")
+		}
+	},
+}
+
+func init() {
+	logsCmd.Flags().BoolP("follow", "f", false, "Follow log output")
+	logsCmd.Flags().Int("tail", 0, "Number of lines to show from the end of the logs (all if 0)")
+
+	if err := viper.BindPFlags(logsCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/cmd/common"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+	"go.dagger.io/dagger/util/buildkitd"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Remove the buildkit daemon",
+	Long: `Remove the buildkit daemon.
+
+The build cache is kept in a volume unless --volumes is set.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+		ctx := lg.WithContext(cmd.Context())
+
+		d, err := buildkitd.GetDriver(ctx, viper.GetString("engine-driver"))
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to get engine driver")
+		}
+
+		if err := buildkitd.Remove(ctx, d, viper.GetBool("volumes")); err != nil {
+			lg.Fatal().Err(err).Msg("failed to remove buildkit")
+		}
+
+		status, err := buildkitd.GetStatus(ctx, d, common.EngineConfig(ctx))
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to get buildkit status")
+		}
+
+		if err := printStatus(os.Stdout, status, viper.GetString("format")); err != nil {
+			lg.Fatal().Err(err).Msg("failed to print buildkit status")
+		}
+	},
+}
+
+func init() {
+	rmCmd.Flags().Bool("volumes", false, "Also remove the build cache volume")
+
+	if err := viper.BindPFlags(rmCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/cmd/common"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+	"go.dagger.io/dagger/util/buildkitd"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print the status of the buildkit daemon",
+	Args:  cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+		ctx := lg.WithContext(cmd.Context())
+
+		d, err := buildkitd.GetDriver(ctx, viper.GetString("engine-driver"))
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to get engine driver")
+		}
+
+		status, err := buildkitd.GetStatus(ctx, d, common.EngineConfig(ctx))
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to get buildkit status")
+		}
+
+		if err := printStatus(os.Stdout, status, viper.GetString("format")); err != nil {
+			lg.Fatal().Err(err).Msg("failed to print buildkit status")
+		}
+	},
+}
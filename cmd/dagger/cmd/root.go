@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"strings"
 
-	"github.com/moby/buildkit/util/appcontext"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/cmd/cache"
+	"go.dagger.io/dagger/cmd/dagger/cmd/debug"
+	"go.dagger.io/dagger/cmd/dagger/cmd/engine"
 	"go.dagger.io/dagger/cmd/dagger/cmd/project"
 	"go.dagger.io/dagger/cmd/dagger/logger"
 
@@ -23,6 +26,8 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.PersistentFlags().String("log-format", "auto", "Log format (auto, plain, tty, json)")
 	rootCmd.PersistentFlags().StringP("log-level", "l", "info", "Log level")
+	rootCmd.PersistentFlags().String("engine-driver", "auto", "Driver running the buildkit daemon (auto, docker, podman, nerdctl, local)")
+	rootCmd.PersistentFlags().String("engine-config", "", "Path to a buildkit daemon config file, merged with the user and project configs")
 
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, _ []string) {
 		go checkVersion()
@@ -35,7 +40,11 @@ func init() {
 		versionCmd,
 		docCmd,
 		doCmd,
+		secretsCmd,
 		project.Cmd,
+		engine.Cmd,
+		cache.Cmd,
+		debug.Cmd,
 	)
 
 	rootCmd.CompletionOptions.HiddenDefaultCmd = true
@@ -50,7 +59,7 @@ func init() {
 
 func Execute() {
 	var (
-		ctx = appcontext.Context()
+		ctx = withInterrupt(context.Background())
 		// `--log-*` flags have not been parsed yet at this point so we get a
 		// default logger. Therefore, we can't store the logger into the context.
 		lg     = logger.New()
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.dagger.io/dagger/cmd/dagger/logger"
+	"go.dagger.io/dagger/plancontext"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets [OPTIONS] ACTION [SUBACTION...]",
+	Short: "List the secrets used by an action, without running it.",
+	Long: `List the secrets used by an action, without running it.
+
+For each secret, print the field it's sourced from (e.g. client.env.TOKEN)
+and the fields of the tasks it's injected into, with the environment variable
+or mount it's injected as. Values are never revealed.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Fix Viper bug for duplicate flags:
+		// https://github.com/spf13/viper/issues/233
+		if err := viper.BindPFlags(cmd.Flags()); err != nil {
+			panic(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		lg := logger.New()
+
+		p, err := loadPlan()
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to load plan")
+		}
+
+		report, err := p.SecretsReport(getTargetPath(args))
+		if err != nil {
+			lg.Fatal().Err(err).Msg("failed to inspect plan")
+		}
+
+		if err := printSecretsReport(os.Stdout, report, viper.GetString("format")); err != nil {
+			lg.Fatal().Err(err).Msg("failed to print secrets")
+		}
+	},
+}
+
+// printSecretsReport prints where secrets are sourced and used, as text or json
+func printSecretsReport(w io.Writer, report []plancontext.SecretUsage, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "text":
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SECRET\tTASK\tINJECTED AS")
+	for _, u := range report {
+		for _, ref := range u.Sources {
+			fmt.Fprintf(tw, "%s\t%s\t\n", ref.Path, ref.Task)
+		}
+		if len(u.Usages) == 0 {
+			fmt.Fprintln(tw, "  (unused)\t\t")
+		}
+		for _, ref := range u.Usages {
+			as := ""
+			switch {
+			case ref.Env != "":
+				as = "env " + ref.Env
+			case ref.Mount != "":
+				as = "mount " + ref.Mount
+			}
+			fmt.Fprintf(tw, "  -> %s\t%s\t%s\n", ref.Path, ref.Task, as)
+		}
+	}
+	return tw.Flush()
+}
+
+func init() {
+	secretsCmd.Flags().StringP("plan", "p", ".", "Path to plan (defaults to current directory)")
+	secretsCmd.Flags().StringArrayP("with", "w", []string{}, "")
+	secretsCmd.Flags().String("format", "text", "Output format (text, json)")
+
+	if err := viper.BindPFlags(secretsCmd.Flags()); err != nil {
+		panic(err)
+	}
+}
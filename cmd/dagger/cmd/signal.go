@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit code of a command interrupted by SIGINT or SIGTERM
+const exitInterrupted = 130
+
+// withInterrupt returns a context canceled by the first SIGINT or SIGTERM, to
+// let the command stop its tasks and clean up. The second signal exits
+// immediately. ctx must not handle these signals itself (e.g. buildkit's
+// appcontext), which would exit on its own terms.
+func withInterrupt(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		cancel()
+
+		<-signals
+		os.Exit(exitInterrupted)
+	}()
+
+	return ctx
+}
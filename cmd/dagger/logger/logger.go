@@ -7,6 +7,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/mattn/go-colorable"
@@ -23,7 +24,7 @@ func New() zerolog.Logger {
 		Logger()
 
 	if !jsonLogs() {
-		logger = logger.Output(&PlainOutput{Out: colorable.NewColorableStderr()})
+		logger = logger.Output(Output())
 	} else {
 		logger = logger.With().Timestamp().Caller().Logger()
 	}
@@ -36,6 +37,14 @@ func New() zerolog.Logger {
 	return logger.Level(lvl)
 }
 
+// Output returns the writer used by New, depending on the log format
+func Output() io.Writer {
+	if jsonLogs() {
+		return os.Stderr
+	}
+	return &PlainOutput{Out: colorable.NewColorableStderr()}
+}
+
 func jsonLogs() bool {
 	switch f := viper.GetString("log-format"); f {
 	case "json":
@@ -32,12 +32,13 @@ func (c *PlainOutput) Write(p []byte) (int, error) {
 
 	source := parseSource(event)
 
-	return fmt.Fprintln(c.Out, colorize.Color(fmt.Sprintf("%s %s %s%s%s",
+	return fmt.Fprintln(c.Out, colorize.Color(fmt.Sprintf("%s %s %s%s%s%s",
 		formatTimestamp(event),
 		formatLevel(event),
 		formatSource(source),
 		formatMessage(event),
 		formatFields(event),
+		formatErrorDetails(event),
 	)))
 }
 
@@ -87,7 +88,7 @@ func formatMessage(event map[string]interface{}) string {
 	}
 	message = strings.TrimSpace(message)
 
-	if err, ok := event[zerolog.ErrorFieldName].(string); ok && err != "" {
+	if err := errorMessage(event); err != "" {
 		message = message + ": " + err
 	}
 
@@ -116,6 +117,61 @@ func formatMessage(event map[string]interface{}) string {
 	}
 }
 
+// errorMessage returns the error of an event. Task errors are objects with the
+// details of the failure (see task.Error).
+func errorMessage(event map[string]interface{}) string {
+	switch err := event[zerolog.ErrorFieldName].(type) {
+	case string:
+		return err
+	case map[string]interface{}:
+		msg, _ := err["message"].(string)
+		return msg
+	default:
+		return ""
+	}
+}
+
+// formatErrorDetails renders the details of a task error, on the lines
+// following the message
+func formatErrorDetails(event map[string]interface{}) string {
+	err, ok := event[zerolog.ErrorFieldName].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	b := &strings.Builder{}
+
+	task, _ := err["task"].(string)
+	fmt.Fprintf(b, "\n    [bold]task:[reset] %s", task)
+	if typ, ok := err["type"].(string); ok && typ != "" {
+		fmt.Fprintf(b, " (#%s)", typ)
+	}
+	if pos, ok := err["pos"].(string); ok {
+		fmt.Fprintf(b, " at %s", pos)
+	}
+
+	if args, ok := err["args"].([]interface{}); ok {
+		quoted := make([]string, 0, len(args))
+		for _, a := range args {
+			quoted = append(quoted, fmt.Sprintf("%q", a))
+		}
+		fmt.Fprintf(b, "\n    [bold]command:[reset] %s", strings.Join(quoted, " "))
+	}
+
+	if code, ok := err["exitCode"].(float64); ok {
+		fmt.Fprintf(b, "\n    [bold]exit code:[reset] %d", int(code))
+	}
+
+	if logs, ok := err["logs"].([]interface{}); ok && len(logs) > 0 {
+		fmt.Fprintf(b, "\n    [bold]output (last %d lines):[reset]", len(logs))
+		for _, line := range logs {
+			fmt.Fprintf(b, "\n      [dim]| %s[reset]", line)
+		}
+	}
+
+	return b.String()
+}
+
 func parseSource(event map[string]interface{}) string {
 	source := "system"
 	if task, ok := event["task"].(string); ok && task != "" {
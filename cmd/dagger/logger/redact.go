@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Redactor hides sensitive values (e.g. secrets) from a string
+type Redactor interface {
+	Redact(string) string
+}
+
+// RedactedOutput scrubs sensitive values from every log event before
+// forwarding it to the underlying output (plain, tty or json).
+type RedactedOutput struct {
+	Out      io.Writer
+	Redactor Redactor
+}
+
+func (c *RedactedOutput) Write(p []byte) (int, error) {
+	event := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+	if err := d.Decode(&event); err != nil {
+		return 0, fmt.Errorf("cannot decode event: %s", err)
+	}
+
+	// Only re-encode events which contained a sensitive value
+	if !c.redact(event) {
+		return c.Out.Write(p)
+	}
+
+	redacted, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.Out.Write(append(redacted, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redact replaces sensitive values in all the strings of a decoded event
+func (c *RedactedOutput) redact(v interface{}) bool {
+	changed := false
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok {
+				if r := c.Redactor.Redact(s); r != s {
+					v[key] = r
+					changed = true
+				}
+				continue
+			}
+			changed = c.redact(value) || changed
+		}
+	case []interface{}:
+		for i, value := range v {
+			if s, ok := value.(string); ok {
+				if r := c.Redactor.Redact(s); r != s {
+					v[i] = r
+					changed = true
+				}
+				continue
+			}
+			changed = c.redact(value) || changed
+		}
+	}
+
+	return changed
+}
@@ -78,8 +78,14 @@ func (l *Logs) Add(event Event) error {
 	// For each task in a group, the status will transition from computing to complete, then back to computing and so on.
 	// The transition is fast enough not to cause a problem.
 	if st, ok := event["state"].(string); ok {
+		// Time queued tasks from when they start computing
+		if group.State == task.StateQueued && task.State(st) == task.StateComputing {
+			now := time.Now()
+			group.Started = &now
+		}
+
 		group.State = task.State(st)
-		if group.State == task.StateComputing {
+		if group.State == task.StateComputing || group.State == task.StateQueued {
 			group.Completed = nil
 		} else {
 			now := time.Now()
@@ -98,6 +104,7 @@ type TTYOutput struct {
 	cons      console.Console
 	logs      *Logs
 	lineCount int
+	paused    bool
 	l         sync.RWMutex
 
 	stopCh  chan struct{}
@@ -125,8 +132,8 @@ func NewTTYOutput(w *os.File) (*TTYOutput, error) {
 }
 
 func (c *TTYOutput) Start() {
-	defer close(c.doneCh)
 	go func() {
+		defer close(c.doneCh)
 		for {
 			select {
 			case <-c.stopCh:
@@ -140,16 +147,40 @@ func (c *TTYOutput) Start() {
 	}()
 }
 
+// Stop renders the final state of the tasks, and stops rendering
 func (c *TTYOutput) Stop() {
-	c.l.Lock()
-	defer c.l.Unlock()
+	c.print()
 
-	if c.doneCh == nil {
+	c.l.Lock()
+	doneCh := c.doneCh
+	if doneCh == nil {
+		c.l.Unlock()
 		return
 	}
-	close(c.stopCh)
-	<-c.doneCh
 	c.doneCh = nil
+	close(c.stopCh)
+	c.l.Unlock()
+
+	// The printer may be waiting for the lock
+	<-doneCh
+}
+
+// Pause stops rendering, e.g. while the user is prompted for input.
+func (c *TTYOutput) Pause() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.paused = true
+}
+
+// Resume starts rendering again, below anything printed while paused.
+func (c *TTYOutput) Resume() {
+	c.l.Lock()
+	c.paused = false
+	c.lineCount = 0
+	c.l.Unlock()
+
+	c.print()
 }
 
 func (c *TTYOutput) Write(p []byte) (n int, err error) {
@@ -179,6 +210,10 @@ func (c *TTYOutput) print() {
 	default:
 	}
 
+	if c.paused {
+		return
+	}
+
 	width, height := c.getSize()
 
 	// hide during re-rendering to avoid flickering
@@ -238,11 +273,12 @@ func (c *TTYOutput) linesPerGroup(width, height int) int {
 }
 
 func (c *TTYOutput) printLine(w io.Writer, event Event, width int) int {
-	message := colorize.Color(fmt.Sprintf("%s %s %s%s",
+	message := colorize.Color(fmt.Sprintf("%s %s %s%s%s",
 		formatTimestamp(event),
 		formatLevel(event),
 		formatMessage(event),
 		formatFields(event),
+		formatErrorDetails(event),
 	))
 
 	// pad
@@ -264,6 +300,8 @@ func (c *TTYOutput) printGroup(group *Group, width, maxLines int) int {
 
 	prefix := ""
 	switch group.State {
+	case task.StateQueued:
+		prefix = "[ ]"
 	case task.StateComputing:
 		prefix = "[+]"
 	case task.StateCanceled:
@@ -294,6 +332,8 @@ func (c *TTYOutput) printGroup(group *Group, width, maxLines int) int {
 
 	// color
 	switch group.State {
+	case task.StateQueued:
+		out = aec.Apply(out, aec.LightBlackF)
 	case task.StateComputing:
 		out = aec.Apply(out, aec.LightBlueF)
 	case task.StateCanceled:
@@ -91,6 +91,7 @@ func (v *Value) IncompleteKind() cue.Kind {
 type Field struct {
 	Selector cue.Selector
 	Value    *Value
+	Optional bool
 }
 
 // Label returns the unquoted selector
@@ -115,6 +116,7 @@ func (v *Value) Fields(opts ...cue.Option) ([]Field, error) {
 		fields = append(fields, Field{
 			Selector: it.Selector(),
 			Value:    v.cc.Wrap(it.Value()),
+			Optional: it.IsOptional(),
 		})
 	}
 
@@ -0,0 +1,11 @@
+dagger.#Plan & {
+	client: env: {
+		// Load variables from a local file, the environment takes precedence
+		$dotenv: [".env"]
+
+		// Defaults to "info" if LOG_LEVEL is not set
+		LOG_LEVEL: string | *"info"
+		// Left unset if NPM_TOKEN is not set
+		NPM_TOKEN?: dagger.#Secret
+	}
+}
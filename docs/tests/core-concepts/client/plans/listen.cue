@@ -0,0 +1,26 @@
+dagger.#Plan & {
+	client: network: "tcp://localhost:8080": listen: dagger.#Socket
+
+	actions: {
+		image: alpine.#Build & {
+			packages: {
+				python3: {}
+				socat: {}
+			}
+		}
+		serve: docker.#Run & {
+			input: image.output
+			mounts: listen: {
+				dest:     "/run/listen.sock"
+				contents: client.network."tcp://localhost:8080".listen
+			}
+			command: {
+				name: "sh"
+				flags: "-c": """
+					python3 -m http.server 8000 &
+					while true; do socat UNIX-CONNECT:/run/listen.sock TCP:localhost:8000; done
+					"""
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+dagger.#Plan & {
+	client: prompt: {
+		environment: {
+			message: "Environment"
+			value:   string
+			options: ["staging", "production"]
+			default: "staging"
+		}
+		confirm: {
+			message: "Deploy"
+			value:   bool
+		}
+		otp: {
+			message: "One-time password"
+			value:   dagger.#Secret
+		}
+	}
+
+	actions: deploy: {
+		// ...
+	}
+}
@@ -0,0 +1,23 @@
+dagger.#Plan & {
+	client: {
+		filesystem: "./secrets.yaml": read: contents: string
+		env: SOPS_AGE_KEY: dagger.#Secret
+	}
+
+	actions: {
+		// Decrypts the file without the sops binary
+		secrets: core.#DecryptSecret & {
+			input:  client.filesystem."./secrets.yaml".read.contents
+			format: "yaml"
+			keys: age: client.env.SOPS_AGE_KEY
+		}
+
+		run: docker.#Run & {
+			mounts: secret: {
+				dest:     "/run/secrets/token"
+				contents: secrets.output.myToken.contents
+			}
+			// Do something with `/run/secrets/token`
+		}
+	}
+}
@@ -0,0 +1,19 @@
+dagger.#Plan & {
+	client: env: NPM_TOKEN: dagger.#Secret
+
+	actions: {
+		// Builds a new secret, without leaking the token
+		npmrc: core.#TemplateSecret & {
+			template: "//registry.npmjs.org/:_authToken={{ .token }}"
+			inputs: token: client.env.NPM_TOKEN
+		}
+
+		publish: docker.#Run & {
+			mounts: npmrc: {
+				dest:     "/root/.npmrc"
+				contents: npmrc.output
+			}
+			// ...
+		}
+	}
+}
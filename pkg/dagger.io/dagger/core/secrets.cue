@@ -3,19 +3,71 @@ package core
 import "dagger.io/dagger"
 
 // Decode the contents of a secrets without leaking it.
-// Supported formats: json, yaml
+// Supported formats: json, yaml, dotenv, ini, toml
 #DecodeSecret: {
 	$dagger: task: _name: "DecodeSecret"
 
-	// A dagger.#Secret whose plain text is a JSON or YAML string
+	// A dagger.#Secret whose plain text is a JSON, YAML, dotenv, INI or TOML string
 	input: dagger.#Secret
 
+	// INI sections are decoded as nested secrets
+	format: "json" | "yaml" | "dotenv" | "ini" | "toml"
+
+	// A new secret or (map of secrets) derived from unmarshaling the input secret's plain text.
+	// Numbers, booleans and dates are converted to strings.
+	// JSON and YAML dotted keys are nested and lists skipped; other formats
+	// keep keys as is and don't support lists.
+	output: dagger.#Secret | {[string]: output}
+}
+
+// Decrypt the contents of a file encrypted with SOPS (https://github.com/mozilla/sops),
+// without relying on the sops binary.
+// Supported formats: json, yaml
+// Supported keys: age, pgp
+#DecryptSecret: {
+	$dagger: task: _name: "DecryptSecret"
+
+	// Encrypted contents, as a secret or a string (e.g. read from a file)
+	input: dagger.#Secret | string
+
 	format: "json" | "yaml"
 
-	// A new secret or (map of secrets) derived from unmarshaling the input secret's plain text
+	// Private keys able to decrypt the file
+	keys: {
+		// age identities (e.g. contents of `keys.txt`)
+		age?: dagger.#Secret
+
+		// Armored PGP private key
+		pgp?: dagger.#Secret
+
+		// Passphrase of the PGP private key, if encrypted
+		pgpPassphrase?: dagger.#Secret
+	}
+
+	// A new secret or (map of secrets) derived from the decrypted values,
+	// just like #DecodeSecret
 	output: dagger.#Secret | {[string]: output}
 }
 
+// Render a Go template with secret inputs into a new secret,
+// without their plain text ever entering CUE.
+// Example: a `.npmrc` or a kubeconfig embedding a token
+#TemplateSecret: {
+	$dagger: task: _name: "TemplateSecret"
+
+	// Go text/template (https://pkg.go.dev/text/template)
+	// Inputs are referenced as `{{ .name }}`.
+	// Available functions: b64enc, b64dec, trim, toJson
+	template: string
+
+	// Values of the template: secrets (replaced with their plain text),
+	// strings, numbers, booleans, lists or structs of them
+	inputs: [string]: _
+
+	// Rendered template
+	output: dagger.#Secret
+}
+
 // Create a new a secret from a filesystem tree
 #NewSecret: {
 	$dagger: task: _name: "NewSecret"
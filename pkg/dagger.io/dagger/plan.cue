@@ -34,6 +34,9 @@ package dagger
 		// Execute commands in the client
 		commands: [id=string]: _#clientCommand
 
+		// Prompt the user of the client for input
+		prompt: [id=string]: _#clientPrompt
+
 		// Platform of the client machine
 		platform: _#clientPlatform
 	}
@@ -41,7 +44,38 @@ package dagger
 	// Configure platform execution
 	platform?: string
 
+	// Share the cache of actions through a registry or a local directory
+	// Overridden by `dagger do --cache-from` and `--cache-to`
+	cache?: {
+		// Load the cache from these sources
+		from?: [..._#cacheImport]
+
+		// Export the cache to these destinations
+		to?: [..._#cacheExport]
+	}
+
+	// Limit how many tasks run at once
+	concurrency?: {
+		// Maximum number of tasks running at once
+		// Overridden by `dagger do --parallel`
+		max?: int & >0
+
+		// Maximum number of tasks of a group running at once
+		groups: [name=string]: {
+			limit: int & >0
+
+			// Types of the tasks in the group, e.g. "Push" for `core.#Push`
+			types: [...string]
+
+			// Actions whose tasks are in the group, relative to `actions`, e.g. "deploy"
+			actions: [...string]
+		}
+	}
+
 	// Execute actions in containers
+	// The `finally` and `onFailure` sub-actions of the actions run with
+	// `dagger do` are hooks, run after them (see #Failure). No hook runs if
+	// `dagger do` is interrupted, e.g. with Ctrl-C, not even `finally`.
 	actions: {
 		...
 	}
@@ -70,6 +104,13 @@ _#clientFilesystemRead: {
 		// Filename patterns to exclude
 		// Example: ["node_modules"]
 		exclude?: [...string]
+
+		// Also exclude files ignored by these ignore files:
+		//     .gitignore: every .gitignore file in the directory tree (and .git directories)
+		//     .dockerignore: the .dockerignore file at the root of the directory
+		// Explicit `exclude` patterns take precedence
+		// Example: [".gitignore"]
+		ignore?: [...(".gitignore" | ".dockerignore")]
 	}
 }
 
@@ -88,6 +129,27 @@ _#clientFilesystemWrite: {
 		// Filesystem contents to export
 		// Reference an #FS field produced by an action
 		contents: #FS
+
+		// Export into a staging directory first, then swap it with `path`.
+		// A failed export never leaves a partially written directory.
+		atomic: *false | bool
+
+		// Delete the files in `path` which are not in `contents`
+		prune: *false | bool
+
+		// Override the permissions of the written files and directories
+		// (defaults to the permissions in `contents`)
+		permissions?: {
+			files?: int
+			dirs?:  int
+		}
+
+		// Override the ownership of the written files and directories
+		// (defaults to the current user)
+		owner?: {
+			uid: int
+			gid: int
+		}
 	}
 }
 
@@ -95,23 +157,35 @@ _#clientNetwork: {
 	$dagger: task: _name: "ClientNetwork"
 
 	// URL to the socket
-	// Example: unix:///var/run/docker.sock
+	// Example: unix:///var/run/docker.sock, tcp://localhost:8080
 	address: #Address
 
 	{
-		// unix socket or npipe
+		// Connect to an existing unix socket, npipe or tcp endpoint
 		connect: #Socket
-		// } | {
-		//  // FIXME: not yet implemented
-		//  listen: #Socket
+	} | {
+		// Accept connections from the client on that address.
+		// Each connection is forwarded to a process connecting
+		// to the socket mounted in a container.
+		listen: #Socket
 	}
 }
 
 _#clientEnv: {
 	$dagger: task: _name: "ClientEnv"
 
-	// CUE type defines expected content
-	[!~"\\$dagger"]: *string | #Secret
+	// Load variables from dotenv files
+	// Paths may be absolute, or relative to client working directory
+	// Variables set in the environment take precedence
+	// Example: [".env"]
+	$dotenv?: [...string]
+
+	// CUE type defines expected content:
+	//     string: value of the variable
+	//     #Secret: secure reference to the value of the variable
+	// Variables must be set, unless the field is optional
+	// (e.g. `FOO?: string`) or has a default (e.g. `string | *"info"`)
+	[!~"^\\$"]: *string | #Secret
 }
 
 _#clientCommand: {
@@ -133,14 +207,54 @@ _#clientCommand: {
 	// Example: {"DEBUG": "1"}
 	env: [string]: string | #Secret
 
+	// Working directory of the command
+	// Path may be absolute, or relative to client working directory
+	dir?: string
+
 	// Capture standard output (as a string or secret)
+	// Output captured as a secret is never logged
 	stdout?: *string | #Secret
 
 	// Capture standard error (as a string or secret)
+	// Output captured as a secret is never logged
 	stderr?: *string | #Secret
 
 	// Inject standard input (from a string or secret)
 	stdin?: string | #Secret
+
+	// If set, capture the exit code of the command
+	// instead of failing on a non-zero exit code
+	exit?: int
+}
+
+_#clientPrompt: {
+	$dagger: task: _name: "ClientPrompt"
+
+	// Message displayed to the user
+	// Example: "Release notes"
+	message: string
+
+	{
+		// CUE type defines expected input:
+		//     string: text typed by the user
+		//     #Secret: hidden input, as a secure reference
+		value: string | #Secret
+
+		// If set, only accept one of these options
+		// Example: ["staging", "production"]
+		options?: [...string]
+
+		// Value used when the user doesn't type anything,
+		// or when not running in an interactive terminal
+		default?: string
+	} | {
+		// Confirmation from the user (yes or no)
+		value: bool
+
+		// Value used when the user doesn't type anything,
+		// or when not running in an interactive terminal
+		default?: bool
+	}
 }
 
 _#clientPlatform: {
@@ -151,3 +265,33 @@ _#clientPlatform: {
 	// Hardware architecture of the client machine
 	arch: string
 }
+
+_#cacheImport: {
+	// Image in a registry, e.g. "registry.example.com/app:cache"
+	type: "registry"
+	ref:  string
+} | {
+	// Directory on the client machine
+	// Path may be absolute, or relative to client working directory
+	type: "local"
+	path: string
+}
+
+_#cacheExport: {
+	{
+		// Image in a registry, e.g. "registry.example.com/app:cache"
+		type: "registry"
+		ref:  string
+	} | {
+		// Directory on the client machine
+		// Path may be absolute, or relative to client working directory
+		type: "local"
+		path: string
+	}
+
+	// Export the layers of the result only (min), or of all intermediate steps (max)
+	mode: *"min" | "max"
+} | {
+	// Embed the cache metadata in the images pushed by the plan
+	type: "inline"
+}
@@ -34,5 +34,26 @@ package dagger
 }
 
 // A network service address
-#Address: string & =~"^(unix://|npipe://).+"
+#Address: string & =~"^(tcp://|unix://|npipe://).+"
 // TODO: #Address: string & =~"^(tcp://|unix://|npipe://|udp://).+"
+
+// The failure of an action, available to its `onFailure` hook:
+//
+//  deploy: {
+//   push: docker.#Push & {...}
+//   onFailure: {
+//    failure: dagger.#Failure
+//    notify: bash.#Run & {env: MESSAGE: failure.message}
+//   }
+//   finally: cleanup: bash.#Run & {...}
+//  }
+#Failure: {
+	// Path of the task which failed, e.g. "actions.deploy.push"
+	task: string
+
+	// Error message of the task
+	message: string
+
+	// Exit code of the command run by the task, -1 if unknown
+	exitCode: int
+}
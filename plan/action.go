@@ -1,6 +1,9 @@
 package plan
 
 import (
+	"fmt"
+	"strings"
+
 	"cuelang.org/go/cue"
 )
 
@@ -31,3 +34,17 @@ func (a *Action) FindByPath(path cue.Path) *Action {
 	}
 	return nil
 }
+
+// ParseActionPath returns the path of an action given by its dot-separated
+// names (e.g. `test.unit`). Names are used as is, like the arguments of
+// `dagger do`: they may contain dashes (e.g. `test-unit`).
+func ParseActionPath(name string) (cue.Path, error) {
+	selectors := []cue.Selector{ActionSelector}
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return cue.Path{}, fmt.Errorf("invalid action %q: empty name", name)
+		}
+		selectors = append(selectors, cue.Str(part))
+	}
+	return cue.MakePath(selectors...), nil
+}
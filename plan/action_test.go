@@ -0,0 +1,20 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseActionPath(t *testing.T) {
+	path, err := ParseActionPath("test-unit")
+	require.NoError(t, err)
+	require.Equal(t, `actions."test-unit"`, path.String())
+
+	path, err = ParseActionPath("test.unit")
+	require.NoError(t, err)
+	require.Equal(t, "actions.test.unit", path.String())
+
+	_, err = ParseActionPath("test..unit")
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"fmt"
+	"path/filepath"
+
+	bk "github.com/moby/buildkit/client"
+	"go.dagger.io/dagger/compiler"
+)
+
+// CacheConfig is where the cache of the actions is imported from and
+// exported to, as declared in the `cache` field of the plan
+type CacheConfig struct {
+	Imports []bk.CacheOptionsEntry
+	Exports []bk.CacheOptionsEntry
+}
+
+// Cache returns the cache configuration of the plan
+func (p *Plan) Cache() CacheConfig {
+	return p.cache
+}
+
+// configCache loads the cache configuration of the plan, if any
+func (p *Plan) configCache() error {
+	cacheField := p.source.Lookup("cache")
+
+	// Ignore if cache is not set in `#Plan`
+	if !cacheField.Exists() {
+		return nil
+	}
+
+	imports, err := cacheEntries(cacheField.Lookup("from"), "src")
+	if err != nil {
+		return err
+	}
+	exports, err := cacheEntries(cacheField.Lookup("to"), "dest")
+	if err != nil {
+		return err
+	}
+
+	p.cache = CacheConfig{
+		Imports: imports,
+		Exports: exports,
+	}
+	return nil
+}
+
+// cacheEntries converts cache sources or destinations to buildkit options.
+// pathAttr is the attribute of local directories.
+func cacheEntries(v *compiler.Value, pathAttr string) ([]bk.CacheOptionsEntry, error) {
+	if !v.Exists() {
+		return nil, nil
+	}
+
+	items, err := v.List()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]bk.CacheOptionsEntry, 0, len(items))
+	for _, item := range items {
+		var cache struct {
+			Type string `json:"type"`
+			Ref  string `json:"ref"`
+			Path string `json:"path"`
+			Mode string `json:"mode"`
+		}
+		if err := item.Decode(&cache); err != nil {
+			return nil, fmt.Errorf("%s: %w", item.Path(), err)
+		}
+
+		attrs := map[string]string{}
+		switch cache.Type {
+		case "registry":
+			attrs["ref"] = cache.Ref
+		case "local":
+			path, err := filepath.Abs(cache.Path)
+			if err != nil {
+				return nil, err
+			}
+			attrs[pathAttr] = path
+		}
+		if cache.Mode != "" {
+			attrs["mode"] = cache.Mode
+		}
+
+		entries = append(entries, bk.CacheOptionsEntry{
+			Type:  cache.Type,
+			Attrs: attrs,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,112 @@
+package plan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue/build"
+	cueload "cuelang.org/go/cue/load"
+	"go.dagger.io/dagger/pkg"
+)
+
+// changedFiles returns the absolute paths of the files changed in the git
+// working tree since a revision, including deleted and untracked files
+func changedFiles(ctx context.Context, rev string) ([]string, error) {
+	out, err := git(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(out)
+
+	// Renamed files are listed as deleted and added
+	diff, err := git(ctx, "diff", "--name-only", "--no-renames", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, "ls-files", "--full-name", "--others", "--exclude-standard", "--", ":/")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, out := range []string{diff, untracked} {
+		scanner := bufio.NewScanner(strings.NewReader(out))
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				files = append(files, filepath.Join(root, filepath.FromSlash(line)))
+			}
+		}
+	}
+	return files, nil
+}
+
+// planChanged returns the first changed file which is part of the plan: one
+// of its CUE files, those of the packages it imports, or a file of cue.mod.
+// Any action may depend on them.
+func planChanged(args []string, changed []string) (string, error) {
+	files := map[string]bool{}
+	instances := cueload.Instances(args, &cueload.Config{})
+	seen := map[*build.Instance]bool{}
+	var walk func(inst *build.Instance) error
+	walk = func(inst *build.Instance) error {
+		if seen[inst] {
+			return nil
+		}
+		seen[inst] = true
+		if inst.Err != nil {
+			return inst.Err
+		}
+		for _, f := range inst.BuildFiles {
+			p, err := filepath.Abs(f.Filename)
+			if err != nil {
+				return err
+			}
+			files[p] = true
+		}
+		for _, imp := range inst.Imports {
+			if err := walk(imp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, inst := range instances {
+		if err := walk(inst); err != nil {
+			return "", err
+		}
+	}
+
+	parent, _ := pkg.GetCueModParent()
+	cueMod, err := filepath.Abs(filepath.Join(parent, "cue.mod"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range changed {
+		if files[f] || strings.HasPrefix(f, cueMod+string(filepath.Separator)) {
+			return f, nil
+		}
+	}
+	return "", nil
+}
+
+func git(ctx context.Context, args ...string) (string, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
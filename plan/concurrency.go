@@ -0,0 +1,158 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cuelang.org/go/cue"
+	"golang.org/x/sync/semaphore"
+)
+
+// concurrencyConfig limits how many tasks run at once, as declared in the
+// `concurrency` field of the plan
+type concurrencyConfig struct {
+	// Maximum number of tasks running at once, unlimited if 0
+	Max int
+
+	Groups []concurrencyGroup
+}
+
+// concurrencyGroup limits how many tasks of some types, or of some actions,
+// run at once
+type concurrencyGroup struct {
+	Name    string
+	Limit   int
+	Types   []string
+	Actions []cue.Path
+}
+
+// configConcurrency loads the concurrency limits of the plan, if any
+func (p *Plan) configConcurrency() error {
+	field := p.source.Lookup("concurrency")
+
+	// Ignore if concurrency is not set in `#Plan`
+	if !field.Exists() {
+		return nil
+	}
+
+	var concurrency struct {
+		Max    int `json:"max"`
+		Groups map[string]struct {
+			Limit   int      `json:"limit"`
+			Types   []string `json:"types"`
+			Actions []string `json:"actions"`
+		} `json:"groups"`
+	}
+	if err := field.Decode(&concurrency); err != nil {
+		return fmt.Errorf("%s: %w", field.Path(), err)
+	}
+
+	p.concurrency = concurrencyConfig{
+		Max: concurrency.Max,
+	}
+	for name, group := range concurrency.Groups {
+		g := concurrencyGroup{
+			Name:  name,
+			Limit: group.Limit,
+			Types: group.Types,
+		}
+		for _, action := range group.Actions {
+			path, err := ParseActionPath(action)
+			if err != nil {
+				return fmt.Errorf("concurrency: group %q: %w", name, err)
+			}
+			g.Actions = append(g.Actions, path)
+		}
+		p.concurrency.Groups = append(p.concurrency.Groups, g)
+	}
+
+	// Groups are always acquired in the same order, to avoid deadlocks
+	sort.Slice(p.concurrency.Groups, func(i, j int) bool {
+		return p.concurrency.Groups[i].Name < p.concurrency.Groups[j].Name
+	})
+
+	return nil
+}
+
+// limiter bounds the number of tasks running at once
+type limiter struct {
+	// Slots of all tasks, nil if unlimited
+	all *semaphore.Weighted
+
+	groups []limiterGroup
+}
+
+type limiterGroup struct {
+	concurrencyGroup
+	slots *semaphore.Weighted
+}
+
+func newLimiter(cfg concurrencyConfig) *limiter {
+	l := &limiter{}
+	if cfg.Max > 0 {
+		l.all = semaphore.NewWeighted(int64(cfg.Max))
+	}
+	for _, group := range cfg.Groups {
+		l.groups = append(l.groups, limiterGroup{
+			concurrencyGroup: group,
+			slots:            semaphore.NewWeighted(int64(group.Limit)),
+		})
+	}
+	return l
+}
+
+// Acquire waits until a task can run. queued is called first if it has to
+// wait. The returned function releases the slots of the task.
+func (l *limiter) Acquire(ctx context.Context, path cue.Path, typ string, queued func()) (func(), error) {
+	slots := []*semaphore.Weighted{}
+	for _, group := range l.groups {
+		if group.contains(path, typ) {
+			slots = append(slots, group.slots)
+		}
+	}
+	// Acquired last, not to hold a slot while waiting for a group
+	if l.all != nil {
+		slots = append(slots, l.all)
+	}
+
+	acquired := []*semaphore.Weighted{}
+	release := func() {
+		for _, s := range acquired {
+			s.Release(1)
+		}
+	}
+
+	for _, s := range slots {
+		if s.TryAcquire(1) {
+			acquired = append(acquired, s)
+			continue
+		}
+
+		if queued != nil {
+			queued()
+			queued = nil
+		}
+		if err := s.Acquire(ctx, 1); err != nil {
+			release()
+			return nil, err
+		}
+		acquired = append(acquired, s)
+	}
+
+	return release, nil
+}
+
+func (g limiterGroup) contains(path cue.Path, typ string) bool {
+	for _, t := range g.Types {
+		if t == typ {
+			return true
+		}
+	}
+	for _, action := range g.Actions {
+		if cuePathHasPrefix(path, action) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package plan
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/require"
+	"go.dagger.io/dagger/compiler"
+)
+
+func TestConfigConcurrency(t *testing.T) {
+	v, err := compiler.Compile("", `
+concurrency: {
+	max: 4
+	groups: deploy: {
+		limit: 1
+		actions: ["build-image.push", "deploy"]
+	}
+}
+`)
+	require.NoError(t, err)
+
+	p := &Plan{source: v}
+	require.NoError(t, p.configConcurrency())
+	require.Equal(t, 4, p.concurrency.Max)
+	require.Len(t, p.concurrency.Groups, 1)
+	require.Equal(t, []cue.Path{
+		cue.ParsePath(`actions."build-image".push`),
+		cue.ParsePath("actions.deploy"),
+	}, p.concurrency.Groups[0].Actions)
+}
+
+// acquire acquires the slots of a task in the background, and reports whether
+// it was queued
+func acquire(ctx context.Context, l *limiter, path, typ string) (queued chan struct{}, done chan func()) {
+	queued = make(chan struct{})
+	done = make(chan func(), 1)
+	go func() {
+		release, err := l.Acquire(ctx, cue.ParsePath(path), typ, func() { close(queued) })
+		if err != nil {
+			close(done)
+			return
+		}
+		done <- release
+	}()
+	return queued, done
+}
+
+func TestLimiterGroups(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(concurrencyConfig{
+		Groups: []concurrencyGroup{
+			{Name: "exec", Limit: 1, Types: []string{"Exec"}},
+			{Name: "deploy", Limit: 1, Actions: []cue.Path{cue.ParsePath(`actions."build-image"`)}},
+		},
+	})
+
+	// Tasks of other types and actions aren't limited
+	release, err := l.Acquire(ctx, cue.ParsePath("actions.test"), "Pull", nil)
+	require.NoError(t, err)
+	defer release()
+
+	release, err = l.Acquire(ctx, cue.ParsePath("actions.test"), "Exec", nil)
+	require.NoError(t, err)
+
+	// A second Exec waits for the first one
+	queued, done := acquire(ctx, l, "actions.other", "Exec")
+	<-queued
+	release()
+	(<-done)()
+
+	// Actions are matched by prefix
+	release, err = l.Acquire(ctx, cue.ParsePath(`actions."build-image".push`), "Push", nil)
+	require.NoError(t, err)
+	queued, done = acquire(ctx, l, `actions."build-image".export`, "Export")
+	<-queued
+	release()
+	(<-done)()
+}
+
+func TestLimiterMax(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(concurrencyConfig{Max: 2})
+
+	releases := []func(){}
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(ctx, cue.ParsePath("actions.test"), "Exec", func() {
+			t.Fatal("task should not be queued")
+		})
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+
+	queued, done := acquire(ctx, l, "actions.test", "Exec")
+	<-queued
+	select {
+	case <-done:
+		t.Fatal("task should wait for a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releases[0]()
+	(<-done)()
+	releases[1]()
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	l := newLimiter(concurrencyConfig{
+		Max: 1,
+		Groups: []concurrencyGroup{
+			{Name: "exec", Limit: 1, Types: []string{"Exec"}},
+		},
+	})
+
+	release, err := l.Acquire(context.Background(), cue.ParsePath("actions.test"), "Pull", nil)
+	require.NoError(t, err)
+
+	// The task gets the slot of its group, then waits for the global one
+	ctx, cancel := context.WithCancel(context.Background())
+	queued, done := acquire(ctx, l, "actions.test", "Exec")
+	<-queued
+	cancel()
+	_, ok := <-done
+	require.False(t, ok)
+
+	// The slot of the group was released
+	release()
+	release, err = l.Acquire(context.Background(), cue.ParsePath("actions.test"), "Exec", func() {
+		t.Fatal("task should not be queued")
+	})
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,336 @@
+package plan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/mitchellh/go-homedir"
+	bkpb "github.com/moby/buildkit/solver/pb"
+	"github.com/tonistiigi/fsutil"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plan/task"
+	"go.dagger.io/dagger/plancontext"
+)
+
+// Where the results of memoized tasks are recorded
+const memoDir = "~/.cache/dagger/memo"
+
+// memoRecord is the result of a successful run of a memoizable task
+type memoRecord struct {
+	Task string `json:"task"`
+
+	// State of the resources written by the task outside of buildkit
+	Fingerprint string `json:"fingerprint"`
+
+	// Result of the task, null if empty
+	Result json.RawMessage `json:"result"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// memoStore records the results of memoizable tasks, by key of their inputs
+type memoStore struct {
+	dir string
+}
+
+func newMemoStore() (*memoStore, error) {
+	dir, err := homedir.Expand(memoDir)
+	if err != nil {
+		return nil, err
+	}
+	return &memoStore{dir: dir}, nil
+}
+
+func (m *memoStore) path(key string) string {
+	return filepath.Join(m.dir, key+".json")
+}
+
+// Get returns the record of a key, if any
+func (m *memoStore) Get(key string) (*memoRecord, error) {
+	data, err := os.ReadFile(m.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &memoRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Put records the result of a task
+func (m *memoStore) Put(key string, record *memoRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+
+	// Write atomically, tasks may run concurrently in several plans
+	tmp, err := os.CreateTemp(m.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path(key))
+}
+
+// memoKey hashes the concrete inputs of a task: its CUE value, the filesystems
+// and secrets it references, and the client directories these filesystems are
+// read from
+func (r *Runner) memoKey(ctx context.Context, path cue.Path, v *compiler.Value) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "v1\x00%s\x00%s\x00", wd, path)
+	if err := r.hashValue(ctx, h, v); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (r *Runner) hashValue(ctx context.Context, w io.Writer, v *compiler.Value) error {
+	switch {
+	case plancontext.IsFSValue(v):
+		// Outputs of the task aren't set yet
+		if v.IsConcreteR() != nil {
+			fmt.Fprint(w, "fs:_\x00")
+			return nil
+		}
+		fs, err := r.pctx.FS.FromValue(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "fs:%s\x00", fs.ID())
+		return r.hashLocalDirs(ctx, w, fs)
+	case plancontext.IsSecretValue(v):
+		if v.IsConcreteR() != nil {
+			fmt.Fprint(w, "secret:_\x00")
+			return nil
+		}
+		secret, err := r.pctx.Secrets.FromValue(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "secret:%s\x00", secret.ID())
+		return nil
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		fields, err := v.Fields()
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(fields, func(i, j int) bool {
+			return fields[i].Selector.String() < fields[j].Selector.String()
+		})
+		fmt.Fprint(w, "{")
+		for _, f := range fields {
+			fmt.Fprintf(w, "%s:", f.Selector.String())
+			if err := r.hashValue(ctx, w, f.Value); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(w, "}")
+	case cue.ListKind:
+		items, err := v.List()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, "[")
+		for _, item := range items {
+			if err := r.hashValue(ctx, w, item); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(w, "]")
+	default:
+		if !v.IsConcrete() {
+			fmt.Fprint(w, "_\x00")
+			return nil
+		}
+		data, err := v.Cue().MarshalJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\x00", data)
+	}
+	return nil
+}
+
+// hashLocalDirs hashes the client directories a filesystem is read from.
+// Filesystem IDs only identify them by path.
+func (r *Runner) hashLocalDirs(ctx context.Context, w io.Writer, fs *plancontext.FS) error {
+	if fs.Result() == nil {
+		return nil
+	}
+	dirs, err := r.pctx.LocalDirs.Paths()
+	if err != nil {
+		return err
+	}
+	st, err := fs.State()
+	if err != nil {
+		return err
+	}
+	def, err := st.Marshal(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, dt := range def.Def {
+		var op bkpb.Op
+		if err := (&op).Unmarshal(dt); err != nil {
+			return err
+		}
+		src := op.GetSource()
+		if src == nil || !strings.HasPrefix(src.Identifier, "local://") {
+			continue
+		}
+
+		opt := &fsutil.WalkOpt{}
+		for attr, patterns := range map[string]*[]string{
+			bkpb.AttrIncludePatterns: &opt.IncludePatterns,
+			bkpb.AttrExcludePatterns: &opt.ExcludePatterns,
+			bkpb.AttrFollowPaths:     &opt.FollowPaths,
+		} {
+			if value, ok := src.Attrs[attr]; ok {
+				if err := json.Unmarshal([]byte(value), patterns); err != nil {
+					return err
+				}
+			}
+		}
+
+		dir := strings.TrimPrefix(src.Identifier, "local://")
+		path, ok := dirs[dir]
+		if !ok {
+			path = dir
+		}
+		fingerprint, err := task.FingerprintPath(ctx, path, opt)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "local:%s:%s\x00", dir, fingerprint)
+	}
+	return nil
+}
+
+// memoizableResult returns whether a result can be recorded. Filesystems and
+// secrets only exist during a run.
+func memoizableResult(v *compiler.Value) bool {
+	if plancontext.IsFSValue(v) || plancontext.IsSecretValue(v) {
+		return false
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		fields, err := v.Fields()
+		if err != nil {
+			return false
+		}
+		for _, f := range fields {
+			if !memoizableResult(f.Value) {
+				return false
+			}
+		}
+	case cue.ListKind:
+		items, err := v.List()
+		if err != nil {
+			return false
+		}
+		for _, item := range items {
+			if !memoizableResult(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// memoized returns the recorded result of a task, if its inputs and the client
+// resources it writes didn't change since then. The key of the task is
+// returned to record its result after it runs.
+func (r *Runner) memoized(ctx context.Context, path cue.Path, handler task.Memoizable, v *compiler.Value) (string, *compiler.Value, error) {
+	key, err := r.memoKey(ctx, path, v)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record, err := r.memo.Get(key)
+	if err != nil || record == nil {
+		return key, nil, err
+	}
+
+	fingerprint, err := handler.Fingerprint(ctx, r.pctx, r.s, v)
+	if err != nil {
+		return key, nil, err
+	}
+	if fingerprint != record.Fingerprint {
+		return key, nil, nil
+	}
+
+	if string(record.Result) == "null" || len(record.Result) == 0 {
+		return key, compiler.NewValue(), nil
+	}
+	result, err := compiler.DecodeJSON(path.String(), record.Result)
+	if err != nil {
+		return key, nil, err
+	}
+	return key, result, nil
+}
+
+// memoize records the result of a successful run of a task
+func (r *Runner) memoize(ctx context.Context, path cue.Path, handler task.Memoizable, v *compiler.Value, key string, result *compiler.Value) error {
+	if !memoizableResult(result) {
+		return nil
+	}
+
+	fingerprint, err := handler.Fingerprint(ctx, r.pctx, r.s, v)
+	if err != nil {
+		return err
+	}
+
+	record := &memoRecord{
+		Task:        path.String(),
+		Fingerprint: fingerprint,
+		Result:      json.RawMessage("null"),
+		CreatedAt:   time.Now().UTC(),
+	}
+	if result.IsConcrete() {
+		data, err := result.Cue().MarshalJSON()
+		if err != nil {
+			return err
+		}
+		record.Result = data
+	}
+
+	return r.memo.Put(key, record)
+}
@@ -0,0 +1,71 @@
+package plan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/require"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/solver"
+)
+
+// stateRef is a reference to the result of a state
+type stateRef struct {
+	bkgw.Reference
+	st llb.State
+}
+
+func (r stateRef) ToState() (llb.State, error) {
+	return r.st, nil
+}
+
+func TestMemoKey(t *testing.T) {
+	ctx := context.Background()
+	pctx := plancontext.New()
+	r := NewRunner(pctx, nil, solver.Solver{})
+	path := cue.ParsePath("actions.test")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte("a"), 0600))
+	pctx.LocalDirs.Add(dir)
+	fs := pctx.FS.New(stateRef{st: llb.Local(dir)})
+
+	key := func(fields map[string]interface{}) string {
+		v := compiler.NewValue()
+		for p, field := range fields {
+			require.NoError(t, v.FillPath(cue.ParsePath(p), field))
+		}
+		k, err := r.memoKey(ctx, path, v)
+		require.NoError(t, err)
+		return k
+	}
+
+	inputs := func(token string) map[string]interface{} {
+		return map[string]interface{}{
+			"input":     fs.MarshalCUE(),
+			"env.TOKEN": pctx.Secrets.New(token).MarshalCUE(),
+			"dest":      "registry/image",
+		}
+	}
+
+	base := key(inputs("secret"))
+	require.Equal(t, base, key(inputs("secret")))
+
+	// Changing a secret changes the key
+	require.NotEqual(t, base, key(inputs("other secret")))
+
+	// Changing a value changes the key
+	changed := inputs("secret")
+	changed["dest"] = "registry/other"
+	require.NotEqual(t, base, key(changed))
+
+	// Changing a client file read by a filesystem changes the key
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte("changed"), 0600))
+	require.NotEqual(t, base, key(inputs("secret")))
+}
@@ -29,12 +29,26 @@ type Plan struct {
 	context *plancontext.Context
 	source  *compiler.Value
 	action  *Action
+	cache   CacheConfig
+
+	concurrency concurrencyConfig
 }
 
 type Config struct {
 	Args   []string
 	With   []string
 	Target string
+
+	// Reuse the results of tasks whose inputs didn't change since their last
+	// run (see task.Memoizable)
+	Memoize bool
+
+	// Only run the actions reading client files changed since this git
+	// revision (see task.ClientReader)
+	ChangedSince string
+
+	// Maximum number of tasks running at once, overriding the plan
+	Parallel int
 }
 
 func Load(ctx context.Context, cfg Config) (*Plan, error) {
@@ -86,6 +100,14 @@ func Load(ctx context.Context, cfg Config) (*Plan, error) {
 		return nil, err
 	}
 
+	if err := p.configCache(); err != nil {
+		return nil, err
+	}
+
+	if err := p.configConcurrency(); err != nil {
+		return nil, err
+	}
+
 	if err := p.prepare(ctx); err != nil {
 		return nil, err
 	}
@@ -167,12 +189,42 @@ func (p *Plan) prepare(ctx context.Context) error {
 	return flow.Run(ctx)
 }
 
-// Do executes an action in the plan
-func (p *Plan) Do(ctx context.Context, path cue.Path, s solver.Solver) error {
+// Do executes actions in the plan, in a single run
+func (p *Plan) Do(ctx context.Context, targets []cue.Path, s solver.Solver) error {
 	ctx, span := otel.Tracer("dagger").Start(ctx, "plan.Up")
 	defer span.End()
 
-	r := NewRunner(p.context, path, s)
+	r := NewRunner(p.context, targets, s)
+
+	concurrency := p.concurrency
+	if p.config.Parallel > 0 {
+		concurrency.Max = p.config.Parallel
+	}
+	r.limiter = newLimiter(concurrency)
+
+	if p.config.Memoize {
+		memo, err := newMemoStore()
+		if err != nil {
+			return err
+		}
+		r.memo = memo
+	}
+	if rev := p.config.ChangedSince; rev != "" {
+		changed, err := changedFiles(ctx, rev)
+		if err != nil {
+			return fmt.Errorf("failed to list files changed since %s: %w", rev, err)
+		}
+		file, err := planChanged(p.config.Args, changed)
+		if err != nil {
+			return fmt.Errorf("failed to list the files of the plan: %w", err)
+		}
+		if file != "" {
+			log.Ctx(ctx).Info().Str("file", file).Msgf("plan changed since %s, running all actions", rev)
+		} else {
+			log.Ctx(ctx).Info().Int("files", len(changed)).Msgf("only running actions affected by changes since %s", rev)
+			r.changed = changed
+		}
+	}
 	return r.Run(ctx, p.source)
 }
 
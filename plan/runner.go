@@ -2,7 +2,10 @@ package plan
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +16,7 @@ import (
 	"go.dagger.io/dagger/solver"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/token"
 	cueflow "cuelang.org/go/tools/flow"
 
 	"github.com/rs/zerolog/log"
@@ -20,27 +24,88 @@ import (
 )
 
 type Runner struct {
-	pctx   *plancontext.Context
-	target cue.Path
-	s      solver.Solver
-	tasks  sync.Map
-	mirror *compiler.Value
-	l      sync.Mutex
+	pctx    *plancontext.Context
+	targets []cue.Path
+	s       solver.Solver
+	tasks   sync.Map
+	mirror  *compiler.Value
+	l       sync.Mutex
+
+	// First task which failed
+	failure *task.Error
+
+	// Results of memoizable tasks, if memoization is enabled
+	memo *memoStore
+
+	// Bounds the number of tasks running at once, if set
+	limiter *limiter
+
+	// Changed client files, to only run the tasks reading them. All tasks run
+	// if nil.
+	changed  []string
+	affected map[string]bool
+	// Tasks of the targets skipped as they're not affected by the changes
+	skipped map[string]bool
+
+	// Hooks of the targets, run after them
+	hooks []cue.Path
+	// Targets which already ran, while running their hooks
+	ran []cue.Path
+	// Results of the tasks which completed, by path
+	completed sync.Map
 }
 
-func NewRunner(pctx *plancontext.Context, target cue.Path, s solver.Solver) *Runner {
+// completedTask is the result of a task, to run hooks after it
+type completedTask struct {
+	path   cue.Path
+	result *compiler.Value
+}
+
+// NewRunner returns a runner for the tasks of several targets. Their common
+// dependencies only run once.
+func NewRunner(pctx *plancontext.Context, targets []cue.Path, s solver.Solver) *Runner {
 	return &Runner{
-		pctx:   pctx,
-		target: target,
-		s:      s,
-		mirror: compiler.NewValue(),
+		pctx:    pctx,
+		targets: targets,
+		s:       s,
+		mirror:  compiler.NewValue(),
 	}
 }
 
 func (r *Runner) Run(ctx context.Context, src *compiler.Value) error {
-	if !src.LookupPath(r.target).Exists() {
-		return fmt.Errorf("%s not found", r.target.String())
+	for _, target := range r.targets {
+		if !src.LookupPath(target).Exists() {
+			return fmt.Errorf("%s not found", target.String())
+		}
+	}
+
+	finally, onFailure := hookPaths(src, r.targets)
+	r.hooks = append(append([]cue.Path{}, finally...), onFailure...)
+
+	err := r.run(ctx, src)
+
+	hooks := finally
+	if err != nil {
+		// Only the targets which failed run their onFailure hooks
+		_, onFailure = hookPaths(src, r.failedTargets(err))
+		hooks = append(hooks, onFailure...)
+	}
+	// Interrupted runs don't run hooks, not even finally hooks: the buildkit
+	// session is canceled with ctx
+	if len(hooks) == 0 || ctx.Err() != nil {
+		return err
+	}
+
+	// The failure of the targets takes precedence over the failure of hooks
+	if hookErr := r.runHooks(ctx, src, hooks, onFailure, err); err == nil {
+		return hookErr
 	}
+	return err
+}
+
+// run runs the tasks of the targets in a flow
+func (r *Runner) run(ctx context.Context, src *compiler.Value) error {
+	defer r.logSkipped(ctx)
 
 	if err := r.update(cue.MakePath(), src); err != nil {
 		return err
@@ -49,12 +114,19 @@ func (r *Runner) Run(ctx context.Context, src *compiler.Value) error {
 	flow := cueflow.New(
 		&cueflow.Config{
 			FindHiddenTasks: true,
+			UpdateFunc:      r.updateFunc,
 		},
 		src.Cue(),
 		r.taskFunc,
 	)
 
 	if err := flow.Run(ctx); err != nil {
+		// Report the failed task with its details, rather than the flow error
+		r.l.Lock()
+		defer r.l.Unlock()
+		if r.failure != nil {
+			return r.failure
+		}
 		return err
 	}
 
@@ -66,6 +138,112 @@ func (r *Runner) Run(ctx context.Context, src *compiler.Value) error {
 	}
 }
 
+// runHooks runs hooks after their targets, with the results of the tasks which
+// completed. onFailure hooks get the failure of the targets.
+func (r *Runner) runHooks(ctx context.Context, src *compiler.Value, hooks, onFailure []cue.Path, failure error) error {
+	v := compiler.NewValue()
+	if err := v.FillPath(cue.MakePath(), src); err != nil {
+		return err
+	}
+
+	// Results which can't be merged back, e.g. recursive disjunctions of
+	// secrets, are left out: hooks referencing them see the plan value instead
+	merged := v.Cue()
+	r.completed.Range(func(_, value interface{}) bool {
+		c := value.(completedTask)
+		if c.result == nil {
+			return true
+		}
+		next := merged.FillPath(c.path, c.result.Cue())
+		if err := next.LookupPath(c.path).Validate(); err != nil {
+			log.Ctx(ctx).Debug().Err(err).Str("task", c.path.String()).Msg("cannot pass task result to hooks")
+			return true
+		}
+		merged = next
+		return true
+	})
+	v = compiler.Wrap(merged)
+
+	if failure != nil {
+		info := r.failureInfo(failure)
+		for _, hook := range onFailure {
+			p := cue.MakePath(append(hook.Selectors(), cue.Str("failure"))...)
+			if err := v.FillPath(p, info); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.l.Lock()
+	r.ran = r.targets
+	r.targets = hooks
+	r.hooks = nil
+	r.failure = nil
+	r.mirror = compiler.NewValue()
+	r.tasks.Range(func(key, _ interface{}) bool {
+		r.tasks.Delete(key)
+		return true
+	})
+	r.l.Unlock()
+
+	return r.run(ctx, v)
+}
+
+// failureInfo describes the failure of the targets, for onFailure hooks (see
+// dagger.#Failure)
+func (r *Runner) failureInfo(err error) map[string]interface{} {
+	info := map[string]interface{}{
+		"task":     "",
+		"message":  r.pctx.Secrets.Redact(err.Error()),
+		"exitCode": -1,
+	}
+
+	var terr *task.Error
+	if errors.As(err, &terr) {
+		info["task"] = terr.Path
+		info["message"] = r.pctx.Secrets.Redact(terr.Err.Error())
+		info["exitCode"] = terr.ExitCode
+	}
+	return info
+}
+
+// failedTargets returns the targets of the task which failed. If the failure
+// isn't of a task of the targets, e.g. of a dependency, all of them failed.
+func (r *Runner) failedTargets(err error) []cue.Path {
+	var terr *task.Error
+	if !errors.As(err, &terr) {
+		return r.targets
+	}
+
+	failed := []cue.Path{}
+	p := cue.ParsePath(terr.Path)
+	for _, target := range r.targets {
+		if cuePathHasPrefix(p, target) {
+			failed = append(failed, target)
+		}
+	}
+	if len(failed) == 0 {
+		return r.targets
+	}
+	return failed
+}
+
+// hookPaths returns the `finally` and `onFailure` hooks of the targets
+func hookPaths(src *compiler.Value, targets []cue.Path) (finally []cue.Path, onFailure []cue.Path) {
+	for _, target := range targets {
+		for name, hooks := range map[string]*[]cue.Path{
+			"finally":   &finally,
+			"onFailure": &onFailure,
+		} {
+			p := cue.MakePath(append(target.Selectors(), cue.Str(name))...)
+			if src.LookupPath(p).Exists() {
+				*hooks = append(*hooks, p)
+			}
+		}
+	}
+	return
+}
+
 func (r *Runner) update(p cue.Path, v *compiler.Value) error {
 	r.l.Lock()
 	defer r.l.Unlock()
@@ -86,11 +264,16 @@ func (r *Runner) initTasks() {
 		noOpRunner,
 	)
 
-	// Allow tasks under the target
+	// Allow tasks under the targets, but not their hooks
 	for _, t := range flow.Tasks() {
-		if cuePathHasPrefix(t.Path(), r.target) {
-			r.addTask(t)
+		if !r.isTarget(t.Path()) || underAny(t.Path(), r.hooks) {
+			continue
+		}
+		if !r.isAffected(t) {
+			r.skip(t)
+			continue
 		}
+		r.addTask(t)
 	}
 
 	// If a `client` task is targeting an allowed task, allow the output task as well
@@ -112,6 +295,11 @@ func (r *Runner) addTask(t *cueflow.Task) {
 		return
 	}
 
+	// Hooks don't run the tasks of their targets which didn't complete
+	if underAny(t.Path(), r.ran) && !r.isTarget(t.Path()) {
+		return
+	}
+
 	r.tasks.Store(t.Path().String(), struct{}{})
 
 	for _, dep := range t.Dependencies() {
@@ -119,6 +307,86 @@ func (r *Runner) addTask(t *cueflow.Task) {
 	}
 }
 
+// isTarget returns whether a task is under one of the targets
+func (r *Runner) isTarget(p cue.Path) bool {
+	return underAny(p, r.targets)
+}
+
+func underAny(p cue.Path, prefixes []cue.Path) bool {
+	for _, prefix := range prefixes {
+		if cuePathHasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAffected returns whether a task reads changed client files, directly or
+// through its dependencies
+func (r *Runner) isAffected(t *cueflow.Task) bool {
+	if r.changed == nil {
+		return true
+	}
+	if r.affected == nil {
+		r.affected = make(map[string]bool)
+	}
+
+	key := t.Path().String()
+	if affected, ok := r.affected[key]; ok {
+		return affected
+	}
+	// Guard against cycles
+	r.affected[key] = false
+
+	affected := false
+	v := compiler.Wrap(t.Value())
+	if handler, err := task.Lookup(v); err == nil {
+		if reader, ok := handler.(task.ClientReader); ok {
+			reads, err := reader.ReadsAny(r.pctx, v, r.changed)
+			// Run the task if unsure
+			affected = reads || err != nil
+		}
+	}
+	for _, dep := range t.Dependencies() {
+		if affected {
+			break
+		}
+		affected = r.isAffected(dep)
+	}
+
+	r.affected[key] = affected
+	return affected
+}
+
+// skip records a task of the targets which isn't affected by the changes, to
+// report it once the run is over
+func (r *Runner) skip(t *cueflow.Task) {
+	if r.skipped == nil {
+		r.skipped = make(map[string]bool)
+	}
+	r.skipped[t.Path().String()] = true
+}
+
+// logSkipped reports the tasks of the targets which were skipped, and didn't
+// run as a dependency of another task
+func (r *Runner) logSkipped(ctx context.Context) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	skipped := make([]string, 0, len(r.skipped))
+	for p := range r.skipped {
+		if _, ok := r.tasks.Load(p); !ok {
+			skipped = append(skipped, p)
+		}
+	}
+	sort.Strings(skipped)
+	for _, p := range skipped {
+		// Not logged as a task, which would show it as pending
+		log.Ctx(ctx).Info().Str("action", p).Msg("skipped: not affected by changes")
+	}
+	r.skipped = nil
+}
+
 func (r *Runner) shouldRun(p cue.Path) bool {
 	_, ok := r.tasks.Load(p.String())
 	return ok
@@ -139,14 +407,39 @@ func (r *Runner) taskFunc(flowVal cue.Value) (cueflow.Runner, error) {
 		return nil, nil
 	}
 
+	// Tasks which ran before hooks are only data
+	if _, ok := r.completed.Load(v.Path().String()); ok {
+		return nil, nil
+	}
+
 	// Wrapper around `task.Run` that handles logging, tracing, etc.
 	return cueflow.RunnerFunc(func(t *cueflow.Task) error {
 		ctx := t.Context()
 		lg := log.Ctx(ctx).With().Str("task", t.Path().String()).Logger()
 		ctx = lg.WithContext(ctx)
+		ctx = solver.WithTask(ctx, t.Path().String())
 		ctx, span := otel.Tracer("dagger").Start(ctx, fmt.Sprintf("up: %s", t.Path().String()))
 		defer span.End()
 
+		// Don't start tasks once the flow is canceled
+		if err := ctx.Err(); err != nil {
+			lg.Error().Str("state", string(task.StateCanceled)).Msg(string(task.StateCanceled))
+			return err
+		}
+
+		// Wait for the concurrency limits of the plan
+		typ, _ := task.TypeOf(v)
+		if r.limiter != nil {
+			release, err := r.limiter.Acquire(ctx, t.Path(), typ, func() {
+				lg.Info().Str("state", string(task.StateQueued)).Msg(string(task.StateQueued))
+			})
+			if err != nil {
+				lg.Error().Str("state", string(task.StateCanceled)).Msg(string(task.StateCanceled))
+				return err
+			}
+			defer release()
+		}
+
 		lg.Info().Str("state", string(task.StateComputing)).Msg(string(task.StateComputing))
 
 		// Debug: dump dependencies
@@ -154,27 +447,79 @@ func (r *Runner) taskFunc(flowVal cue.Value) (cueflow.Runner, error) {
 			lg.Debug().Str("dependency", dep.Path().String()).Msg("dependency detected")
 		}
 
+		// Audit the secrets injected into the task
+		inputs := map[*plancontext.Secret]struct{}{}
+		findSecrets(r.pctx, compiler.Wrap(t.Value()), func(p cue.Path, s *plancontext.Secret) {
+			inputs[s] = struct{}{}
+			r.pctx.Secrets.AddUsage(s, usageRef(compiler.Wrap(t.Value()), typ, p))
+		})
+
+		// Skip the task if it already ran with the same inputs
+		memoizable, _ := handler.(task.Memoizable)
+		memoKey := ""
+		if r.memo != nil && memoizable != nil {
+			key, result, err := r.memoized(ctx, t.Path(), memoizable, compiler.Wrap(t.Value()))
+			switch {
+			case err != nil:
+				lg.Warn().Err(err).Msg("failed to look up memoized result")
+			case result != nil:
+				lg.Info().Dur("duration", 0).Bool("memoized", true).Str("state", string(task.StateCompleted)).Msg(string(task.StateCompleted))
+				if !result.IsConcrete() {
+					r.complete(t.Path(), nil)
+					return nil
+				}
+				r.complete(t.Path(), result)
+				return t.Fill(result.Cue())
+			default:
+				memoKey = key
+			}
+		}
+
 		start := time.Now()
 		result, err := handler.Run(ctx, r.pctx, r.s, compiler.Wrap(t.Value()))
 		if err != nil {
-			// FIXME: this should use errdefs.IsCanceled(err)
-			if strings.Contains(err.Error(), "context canceled") {
+			terr := r.taskError(t, typ, err)
+
+			// The flow was canceled, e.g. by an interrupt
+			if ctx.Err() != nil || solver.IsCanceled(err) {
 				lg.Error().Dur("duration", time.Since(start)).Str("state", string(task.StateCanceled)).Msg(string(task.StateCanceled))
 			} else {
-				lg.Error().Dur("duration", time.Since(start)).Err(compiler.Err(err)).Str("state", string(task.StateFailed)).Msg(string(task.StateFailed))
+				lg.Error().Dur("duration", time.Since(start)).Err(terr).Str("state", string(task.StateFailed)).Msg(string(task.StateFailed))
+
+				r.l.Lock()
+				if r.failure == nil {
+					r.failure = terr
+				}
+				r.l.Unlock()
 			}
-			return fmt.Errorf("%s: %w", t.Path().String(), compiler.Err(err))
+			return terr
 		}
 
 		lg.Info().Dur("duration", time.Since(start)).Str("state", string(task.StateCompleted)).Msg(string(task.StateCompleted))
 
+		if memoKey != "" {
+			if err := r.memoize(ctx, t.Path(), memoizable, compiler.Wrap(t.Value()), memoKey, result); err != nil {
+				lg.Warn().Err(err).Msg("failed to record memoized result")
+			}
+		}
+
 		// If the result is not concrete (e.g. empty value), there's nothing to merge.
 		if !result.IsConcrete() {
+			r.complete(t.Path(), nil)
 			return nil
 		}
 
+		// Audit the secrets sourced by the task (not passed through from its inputs)
+		findSecrets(r.pctx, result, func(p cue.Path, s *plancontext.Secret) {
+			if _, ok := inputs[s]; ok {
+				return
+			}
+			p = cue.MakePath(append(t.Path().Selectors(), p.Selectors()...)...)
+			r.pctx.Secrets.AddSource(s, plancontext.SecretRef{Path: p.String(), Task: typ})
+		})
+
 		if src, err := result.Source(); err == nil {
-			lg.Debug().Str("result", string(src)).Msg("merging task result")
+			lg.Debug().Str("result", r.pctx.Secrets.Redact(string(src))).Msg("merging task result")
 		}
 
 		// Mirror task result and re-scan tasks that should run.
@@ -187,11 +532,112 @@ func (r *Runner) taskFunc(flowVal cue.Value) (cueflow.Runner, error) {
 			lg.Error().Err(err).Msg("failed to fill task")
 			return err
 		}
+		r.complete(t.Path(), result)
 
 		return nil
 	}), nil
 }
 
+// complete records the result of a task, for the hooks of its target
+func (r *Runner) complete(p cue.Path, result *compiler.Value) {
+	r.completed.Store(p.String(), completedTask{path: p, result: result})
+}
+
+// updateFunc records the value of the tasks which completed, with their
+// results merged
+func (r *Runner) updateFunc(c *cueflow.Controller, t *cueflow.Task) error {
+	if t == nil {
+		return nil
+	}
+	if _, ok := r.completed.Load(t.Path().String()); ok {
+		r.completed.Store(t.Path().String(), completedTask{path: t.Path(), result: compiler.Wrap(t.Value())})
+	}
+	return nil
+}
+
+// taskError describes the failure of a task, with the command which failed,
+// if any
+func (r *Runner) taskError(t *cueflow.Task, typ string, err error) *task.Error {
+	terr := &task.Error{
+		Path:     t.Path().String(),
+		Type:     typ,
+		ExitCode: -1,
+		Err:      compiler.Err(err),
+	}
+
+	if pos := taskPos(t.Value()); pos.IsValid() {
+		terr.Pos = pos.String()
+	}
+
+	if exec := solver.ExecFailure(err); exec != nil {
+		terr.Args = exec.Args
+		terr.ExitCode = exec.ExitCode
+		terr.Logs = []string{}
+		for _, line := range r.s.Output(exec.Vertex) {
+			terr.Logs = append(terr.Logs, r.pctx.Secrets.Redact(line))
+		}
+	}
+
+	return terr
+}
+
+// taskPos returns where a task is declared in the plan, rather than in the
+// packages defining its type
+func taskPos(v cue.Value) token.Pos {
+	pos := v.Pos()
+	if pos.IsValid() && !inCueMod(pos) {
+		return pos
+	}
+
+	_, conjuncts := v.Expr()
+	for _, c := range conjuncts {
+		p := c.Pos()
+		if !p.IsValid() {
+			continue
+		}
+		if !inCueMod(p) {
+			return p
+		}
+		if !pos.IsValid() {
+			pos = p
+		}
+	}
+	return pos
+}
+
+func inCueMod(pos token.Pos) bool {
+	return strings.Contains(filepath.ToSlash(pos.Filename()), "/cue.mod/")
+}
+
+// findSecrets calls fn for each concrete secret referenced by a value
+func findSecrets(pctx *plancontext.Context, v *compiler.Value, fn func(cue.Path, *plancontext.Secret)) {
+	if plancontext.IsSecretValue(v) {
+		if s, err := pctx.Secrets.FromValue(v); err == nil {
+			fn(v.Path(), s)
+		}
+		return
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		fields, err := v.Fields()
+		if err != nil {
+			return
+		}
+		for _, field := range fields {
+			findSecrets(pctx, field.Value, fn)
+		}
+	case cue.ListKind:
+		items, err := v.List()
+		if err != nil {
+			return
+		}
+		for _, item := range items {
+			findSecrets(pctx, item, fn)
+		}
+	}
+}
+
 func cuePathHasPrefix(p cue.Path, prefix cue.Path) bool {
 	pathSelectors := p.Selectors()
 	prefixSelectors := prefix.Selectors()
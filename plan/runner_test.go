@@ -0,0 +1,32 @@
+package plan
+
+import (
+	"errors"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/require"
+	"go.dagger.io/dagger/plan/task"
+)
+
+func TestFailedTargets(t *testing.T) {
+	targets := []cue.Path{}
+	for _, name := range []string{"succeed", "test-unit"} {
+		p, err := ParseActionPath(name)
+		require.NoError(t, err)
+		targets = append(targets, p)
+	}
+	r := &Runner{targets: targets}
+
+	// A task of a target
+	failed := r.failedTargets(&task.Error{Path: `actions."test-unit".run`, Err: errors.New("failed")})
+	require.Equal(t, targets[1:], failed)
+
+	// A dependency of the targets
+	failed = r.failedTargets(&task.Error{Path: "actions.image", Err: errors.New("failed")})
+	require.Equal(t, targets, failed)
+
+	// Not a task
+	failed = r.failedTargets(errors.New("failed"))
+	require.Equal(t, targets, failed)
+}
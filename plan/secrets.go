@@ -0,0 +1,256 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+
+	"cuelang.org/go/cue"
+	cueflow "cuelang.org/go/tools/flow"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plan/task"
+	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/solver"
+)
+
+// SecretsReport inspects the plan without running it: for each secret of the
+// tasks needed by target, it lists the field the secret is sourced from and
+// the task fields it's injected into.
+func (p *Plan) SecretsReport(target cue.Path) ([]plancontext.SecretUsage, error) {
+	if !p.source.LookupPath(target).Exists() {
+		return nil, fmt.Errorf("%s not found", target.String())
+	}
+
+	r := NewRunner(p.context, []cue.Path{target}, solver.Solver{})
+	if err := r.update(cue.MakePath(), p.source); err != nil {
+		return nil, err
+	}
+
+	flow := cueflow.New(
+		&cueflow.Config{
+			FindHiddenTasks: true,
+		},
+		p.source.Cue(),
+		noOpRunner,
+	)
+
+	// Type of the tasks to run, by path
+	types := make(map[string]string)
+	tasks := []*cueflow.Task{}
+	for _, t := range flow.Tasks() {
+		if !r.shouldRun(t.Path()) {
+			continue
+		}
+		typ, err := task.TypeOf(compiler.Wrap(t.Value()))
+		if err != nil {
+			return nil, err
+		}
+		types[t.Path().String()] = typ
+		tasks = append(tasks, t)
+	}
+
+	// typeOf returns the type of the task holding a field
+	typeOf := func(p cue.Path) string {
+		sels := p.Selectors()
+		for i := len(sels); i > 0; i-- {
+			if typ, ok := types[cue.MakePath(sels[:i]...).String()]; ok {
+				return typ
+			}
+		}
+		return ""
+	}
+
+	// Secrets, indexed by the path they're sourced from
+	usages := make(map[string]*plancontext.SecretUsage)
+	usage := func(source cue.Path) *plancontext.SecretUsage {
+		u, ok := usages[source.String()]
+		if !ok {
+			u = &plancontext.SecretUsage{
+				Sources: []plancontext.SecretRef{{Path: source.String(), Task: typeOf(source)}},
+				Usages:  []plancontext.SecretRef{},
+			}
+			usages[source.String()] = u
+		}
+		return u
+	}
+
+	for _, t := range tasks {
+		typ := types[t.Path().String()]
+		p.findSecretFields(compiler.Wrap(t.Value()), func(field *compiler.Value, source cue.Path, ok bool) {
+			if !ok {
+				// The secret is produced by the task itself
+				usage(field.Path())
+				return
+			}
+			u := usage(source)
+			ref := usageRef(compiler.Wrap(t.Value()), typ, field.Path())
+			for _, r := range u.Usages {
+				if r == ref {
+					return
+				}
+			}
+			u.Usages = append(u.Usages, ref)
+		})
+	}
+
+	report := make([]plancontext.SecretUsage, 0, len(usages))
+	for _, u := range usages {
+		report = append(report, *u)
+	}
+	plancontext.SortSecretUsages(report)
+
+	return report, nil
+}
+
+// usageRef locates a secret field of a task, with the environment variable
+// (`env: NAME`) or the mount (`mounts: name: contents`) it's injected as
+func usageRef(t *compiler.Value, typ string, field cue.Path) plancontext.SecretRef {
+	ref := plancontext.SecretRef{Path: field.String(), Task: typ}
+
+	sels := field.Selectors()
+	prefix := len(t.Path().Selectors())
+	if len(sels) <= prefix {
+		return ref
+	}
+	rel := sels[prefix:]
+
+	switch {
+	case len(rel) == 2 && rel[0].String() == "env":
+		ref.Env = selectorLabel(rel[1])
+	case len(rel) == 3 && rel[0].String() == "mounts" && rel[2].String() == "contents":
+		dest, err := t.LookupPath(cue.MakePath(rel[0], rel[1], cue.Str("dest"))).String()
+		if err == nil {
+			ref.Mount = dest
+		}
+	}
+	return ref
+}
+
+// selectorLabel returns the unquoted label of a selector
+func selectorLabel(sel cue.Selector) string {
+	label := sel.String()
+	if unquoted, err := strconv.Unquote(label); err == nil {
+		return unquoted
+	}
+	return label
+}
+
+// findSecretFields calls fn for each field of a task typed as a secret, or
+// referencing a secret which isn't known yet (e.g. decoded by another task)
+func (p *Plan) findSecretFields(v *compiler.Value, fn func(field *compiler.Value, source cue.Path, ok bool)) {
+	p.walkSecretFields(v, false, fn)
+}
+
+func (p *Plan) walkSecretFields(v *compiler.Value, disjunct bool, fn func(field *compiler.Value, source cue.Path, ok bool)) {
+	source, ok := p.secretSource(v)
+
+	// Values which may not be secrets once resolved can't be sources
+	if plancontext.IsSecretValue(v) && (ok || !disjunct) {
+		fn(v, source, ok)
+		return
+	}
+
+	if ok && p.isSecretPath(source) {
+		fn(v, source, ok)
+		return
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		fields, err := v.Fields()
+		if err == nil {
+			for _, field := range fields {
+				p.walkSecretFields(field.Value, disjunct, fn)
+			}
+			return
+		}
+
+		// Disjunctions are only resolved when running the plan (e.g. the
+		// type of a mount depends on its contents): look into each part
+		op, args := v.Cue().Expr()
+		if op != cue.AndOp && op != cue.OrOp {
+			return
+		}
+		for _, arg := range args {
+			p.walkSecretFields(compiler.Wrap(arg), disjunct || op == cue.OrOp, fn)
+		}
+	case cue.ListKind:
+		items, err := v.List()
+		if err != nil {
+			return
+		}
+		for _, item := range items {
+			p.walkSecretFields(item, disjunct, fn)
+		}
+	}
+}
+
+// isSecretPath returns whether a field is, or is part of a value which may be
+// a secret (e.g. `output: dagger.#Secret | {[string]: output}`)
+func (p *Plan) isSecretPath(path cue.Path) bool {
+	sels := path.Selectors()
+	for i := len(sels); i > 0; i-- {
+		v := p.source.LookupPath(cue.MakePath(sels[:i]...))
+		if plancontext.IsSecretValue(v) {
+			return true
+		}
+		if op, args := v.Cue().Expr(); op == cue.OrOp {
+			for _, arg := range args {
+				if plancontext.IsSecretValue(compiler.Wrap(arg)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// secretSource follows the references of a secret field up to the field it
+// was sourced from
+func (p *Plan) secretSource(v *compiler.Value) (cue.Path, bool) {
+	var source cue.Path
+	found := false
+
+	// Guard against reference cycles
+	for i := 0; i < 100; i++ {
+		ref, ok := secretReference(v)
+		if !ok {
+			break
+		}
+		source, found = ref, true
+		v = p.source.LookupPath(ref)
+	}
+
+	return source, found
+}
+
+// secretReference returns the path referenced by a field. Fields of tasks
+// are unified with their definition (e.g. `contents: dagger.#Secret`), which
+// is not a reference to a secret.
+func secretReference(v *compiler.Value) (cue.Path, bool) {
+	candidates := []cue.Value{v.Cue()}
+	if op, args := v.Cue().Expr(); op == cue.AndOp {
+		candidates = append(candidates, args...)
+	}
+
+	for _, c := range candidates {
+		_, ref := c.ReferencePath()
+
+		// References to fields which don't exist yet (e.g. `decode.output.KEY`)
+		// can't be resolved: resolve the parent instead
+		if op, args := c.Expr(); len(ref.Selectors()) == 0 && op == cue.SelectorOp && len(args) == 2 {
+			_, parent := args[0].ReferencePath()
+			label, err := args[1].String()
+			if err == nil && len(parent.Selectors()) > 0 {
+				ref = cue.MakePath(append(parent.Selectors(), cue.Str(label))...)
+			}
+		}
+
+		sels := ref.Selectors()
+		if len(sels) == 0 || sels[0].IsDefinition() {
+			continue
+		}
+		return ref, true
+	}
+
+	return cue.Path{}, false
+}
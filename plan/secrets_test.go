@@ -0,0 +1,44 @@
+package plan
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/require"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plancontext"
+)
+
+func TestUsageRef(t *testing.T) {
+	v, err := compiler.Compile("", `
+actions: test: {
+	env: "API-TOKEN": _
+	mounts: token: {
+		dest:     "/run/secrets/token"
+		contents: _
+	}
+	auth: secret: _
+}
+`)
+	require.NoError(t, err)
+	task := v.LookupPath(cue.ParsePath("actions.test"))
+
+	ref := func(field string) plancontext.SecretRef {
+		return usageRef(task, "Exec", cue.ParsePath("actions.test."+field))
+	}
+
+	require.Equal(t, plancontext.SecretRef{
+		Path: `actions.test.env."API-TOKEN"`,
+		Task: "Exec",
+		Env:  "API-TOKEN",
+	}, ref(`env."API-TOKEN"`))
+	require.Equal(t, plancontext.SecretRef{
+		Path:  "actions.test.mounts.token.contents",
+		Task:  "Exec",
+		Mount: "/run/secrets/token",
+	}, ref("mounts.token.contents"))
+	require.Equal(t, plancontext.SecretRef{
+		Path: "actions.test.auth.secret",
+		Task: "Exec",
+	}, ref("auth.secret"))
+}
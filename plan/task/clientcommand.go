@@ -1,6 +1,7 @@
 package task
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/plancontext"
 	"go.dagger.io/dagger/solver"
+	"golang.org/x/sync/errgroup"
 )
 
 func init() {
@@ -58,7 +60,7 @@ func (t clientCommandTask) Run(ctx context.Context, pctx *plancontext.Context, s
 		return nil, err
 	}
 
-	env := make([]string, len(envs))
+	env := make([]string, 0, len(envs))
 	for _, envvar := range envs {
 		s, err := t.getString(pctx, envvar.Value)
 		if err != nil {
@@ -73,6 +75,17 @@ func (t clientCommandTask) Run(ctx context.Context, pctx *plancontext.Context, s
 	cmd := exec.CommandContext(ctx, opts.Name, opts.Args...) //#nosec G204
 	cmd.Env = append(os.Environ(), env...)
 
+	if d := v.Lookup("dir"); d.Exists() {
+		dir, err := d.String()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Dir, err = clientFilePath(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if i := v.Lookup("stdin"); i.Exists() {
 		val, err := t.getString(pctx, i)
 		if err != nil {
@@ -104,29 +117,40 @@ func (t clientCommandTask) Run(ctx context.Context, pctx *plancontext.Context, s
 		return nil, err
 	}
 
-	stdoutVal, err := t.readPipe(&stdout, pctx, v.Lookup("stdout"))
-	if err != nil {
-		return nil, err
-	}
-
-	stderrVal, err := t.readPipe(&stderr, pctx, v.Lookup("stderr"))
-	if err != nil {
+	// Read both pipes concurrently so that neither of them blocks the command
+	var stdoutVal, stderrVal *compiler.Value
+	eg := errgroup.Group{}
+	eg.Go(func() (err error) {
+		stdoutVal, err = t.readPipe(ctx, stdout, pctx, v.Lookup("stdout"))
+		return
+	})
+	eg.Go(func() (err error) {
+		stderrVal, err = t.readPipe(ctx, stderr, pctx, v.Lookup("stderr"))
+		return
+	})
+	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
 
+	exitCode := 0
 	if err := cmd.Wait(); err != nil {
 		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			// FIXME: stderr may be requested as a secret
-			lg.Err(err).Msg(string(exitErr.Stderr))
+		if !errors.As(err, &exitErr) || !v.Lookup("exit").Exists() {
+			return nil, err
 		}
-		return nil, err
+		exitCode = exitErr.ExitCode()
+		lg.Debug().Int("exit", exitCode).Msg("client command failed")
 	}
 
-	return compiler.NewValue().FillFields(map[string]interface{}{
+	fields := map[string]interface{}{
 		"stdout": stdoutVal,
 		"stderr": stderrVal,
-	})
+	}
+	if v.Lookup("exit").Exists() {
+		fields["exit"] = exitCode
+	}
+
+	return compiler.NewValue().FillFields(fields)
 }
 
 func (t clientCommandTask) getString(pctx *plancontext.Context, v *compiler.Value) (string, error) {
@@ -146,20 +170,40 @@ func (t clientCommandTask) getString(pctx *plancontext.Context, v *compiler.Valu
 	return s, nil
 }
 
-func (t clientCommandTask) readPipe(pipe *io.ReadCloser, pctx *plancontext.Context, v *compiler.Value) (*compiler.Value, error) {
-	slurp, err := io.ReadAll(*pipe)
-	if err != nil {
-		return nil, err
-	}
+// readPipe streams the output of the command into the task logs while
+// capturing it. Output captured as a secret is never logged.
+func (t clientCommandTask) readPipe(ctx context.Context, pipe io.Reader, pctx *plancontext.Context, v *compiler.Value) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
 
-	read := string(slurp)
 	val, _ := v.Default()
+	isSecret := plancontext.IsSecretValue(val)
+
+	var (
+		read   strings.Builder
+		reader = bufio.NewReader(pipe)
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		read.WriteString(line)
+
+		if line != "" && !isSecret {
+			lg.Info().Msg(pctx.Secrets.Redact(line))
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	out := compiler.NewValue()
 
-	if plancontext.IsSecretValue(val) {
-		secret := pctx.Secrets.New(read)
+	if isSecret {
+		secret := pctx.Secrets.New(read.String())
 		return out.Fill(secret.MarshalCUE())
 	}
 
-	return out.Fill(read)
+	return out.Fill(read.String())
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"cuelang.org/go/cue"
+	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/plancontext"
@@ -20,20 +22,44 @@ type clientEnvTask struct {
 }
 
 func (t clientEnvTask) Run(ctx context.Context, pctx *plancontext.Context, _ solver.Solver, v *compiler.Value) (*compiler.Value, error) {
-	log.Ctx(ctx).Debug().Msg("loading environment variables")
+	lg := log.Ctx(ctx)
+	lg.Debug().Msg("loading environment variables")
 
-	fields, err := v.Fields()
+	dotenv, err := t.loadDotenv(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := v.Fields(cue.Optional(true))
 	if err != nil {
 		return nil, err
 	}
 
 	envs := make(map[string]interface{})
 	for _, field := range fields {
-		if field.Selector == cue.Str("$dagger") {
+		// Skip reserved fields (e.g. `$dagger`, `$dotenv`)
+		if strings.HasPrefix(field.Selector.String(), "$") {
 			continue
 		}
 		envvar := field.Label()
-		val, err := t.getEnv(envvar, field.Value, pctx)
+
+		env, ok := os.LookupEnv(envvar)
+		if !ok {
+			env, ok = dotenv[envvar]
+		}
+		if !ok {
+			if field.Optional {
+				lg.Debug().Str("envvar", envvar).Msg("optional environment variable not set")
+				continue
+			}
+			if val, hasDefault := field.Value.Default(); hasDefault && val.IsConcrete() {
+				lg.Debug().Str("envvar", envvar).Msg("environment variable not set, using default")
+				continue
+			}
+			return nil, fmt.Errorf("environment variable %q not set", envvar)
+		}
+
+		val, err := t.getEnv(envvar, env, field.Value, pctx)
 		if err != nil {
 			return nil, err
 		}
@@ -43,20 +69,55 @@ func (t clientEnvTask) Run(ctx context.Context, pctx *plancontext.Context, _ sol
 	return compiler.NewValue().FillFields(envs)
 }
 
-func (t clientEnvTask) getEnv(envvar string, v *compiler.Value, pctx *plancontext.Context) (interface{}, error) {
-	env := os.Getenv(envvar)
-	if env == "" {
-		return nil, fmt.Errorf("environment variable %q not set", envvar)
+// loadDotenv reads the variables of the `$dotenv` files.
+// Later files take precedence over earlier ones.
+func (t clientEnvTask) loadDotenv(ctx context.Context, v *compiler.Value) (map[string]string, error) {
+	envs := make(map[string]string)
+
+	files := v.Lookup("$dotenv")
+	if !files.Exists() {
+		return envs, nil
+	}
+
+	var paths []string
+	if err := files.Decode(&paths); err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		path, err := clientFilePath(p)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Ctx(ctx).Debug().Str("path", path).Msg("loading dotenv file")
+
+		vars, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dotenv file %q: %w", p, err)
+		}
+		for k, v := range vars {
+			envs[k] = v
+		}
 	}
 
+	return envs, nil
+}
+
+func (t clientEnvTask) getEnv(envvar, env string, v *compiler.Value, pctx *plancontext.Context) (interface{}, error) {
 	// Resolve default in disjunction if a type hasn't been specified
-	val, _ := v.Default()
+	val, hasDefault := v.Default()
 
 	if plancontext.IsSecretValue(val) {
 		secret := pctx.Secrets.New(env)
 		return secret.MarshalCUE(), nil
 	}
 
+	// A concrete default is only a fallback: check the type of the field instead
+	if hasDefault && val.IsConcrete() {
+		val = v
+	}
+
 	if val.IsConcrete() {
 		return nil, fmt.Errorf("%s: unexpected concrete value, please use a type", envvar)
 	}
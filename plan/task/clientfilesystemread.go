@@ -1,13 +1,20 @@
 package task
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"cuelang.org/go/cue"
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/dockerignore"
 	"github.com/rs/zerolog/log"
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/plancontext"
@@ -98,12 +105,8 @@ func (t clientFilesystemReadTask) readContents(ctx context.Context, pctx *planco
 }
 
 func (t clientFilesystemReadTask) readFS(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value, path string) (*compiler.Value, error) {
-	var dir struct {
-		Include []string
-		Exclude []string
-	}
-
-	if err := v.Decode(&dir); err != nil {
+	include, exclude, err := t.patterns(v, path)
+	if err != nil {
 		return nil, err
 	}
 
@@ -115,16 +118,10 @@ func (t clientFilesystemReadTask) readFS(ctx context.Context, pctx *plancontext.
 		llb.SharedKeyHint(path),
 	}
 
-	if len(dir.Include) > 0 {
-		opts = append(opts, llb.IncludePatterns(dir.Include))
+	if len(include) > 0 {
+		opts = append(opts, llb.IncludePatterns(include))
 	}
-
-	// Excludes .dagger directory by default
-	excludePatterns := []string{"**/.dagger/"}
-	if len(dir.Exclude) > 0 {
-		excludePatterns = dir.Exclude
-	}
-	opts = append(opts, llb.ExcludePatterns(excludePatterns))
+	opts = append(opts, llb.ExcludePatterns(exclude))
 
 	// FIXME: Remove the `Copy` and use `Local` directly.
 	//
@@ -151,6 +148,107 @@ func (t clientFilesystemReadTask) readFS(ctx context.Context, pctx *plancontext.
 	return fs.MarshalCUE(), nil
 }
 
+// patterns returns the include and exclude patterns of a directory
+func (t clientFilesystemReadTask) patterns(v *compiler.Value, path string) (include []string, exclude []string, err error) {
+	var dir struct {
+		Include []string
+		Exclude []string
+		Ignore  []string
+	}
+
+	if err := v.Decode(&dir); err != nil {
+		return nil, nil, err
+	}
+
+	// Patterns from ignore files come first, so that explicit patterns
+	// take precedence
+	exclude = []string{}
+	for _, ignore := range dir.Ignore {
+		var patterns []string
+		switch ignore {
+		case ".gitignore":
+			patterns, err = t.gitignorePatterns(path)
+		case ".dockerignore":
+			patterns, err = t.dockerignorePatterns(path)
+		default:
+			err = fmt.Errorf("unsupported ignore file %q", ignore)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		exclude = append(exclude, patterns...)
+	}
+
+	// Excludes .dagger directory by default
+	if len(dir.Exclude) > 0 {
+		exclude = append(exclude, dir.Exclude...)
+	} else {
+		exclude = append(exclude, "**/.dagger/")
+	}
+
+	return dir.Include, exclude, nil
+}
+
+// ReadsAny implements ClientReader
+func (t clientFilesystemReadTask) ReadsAny(pctx *plancontext.Context, v *compiler.Value, files []string) (bool, error) {
+	contents := v.Lookup("contents")
+	if plancontext.IsServiceValue(contents) {
+		return false, nil
+	}
+
+	path, err := t.parsePath(v)
+	if err != nil {
+		return false, err
+	}
+	// Changed files are resolved by git
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	var include, exclude *fileutils.PatternMatcher
+	if plancontext.IsFSValue(contents) {
+		includePatterns, excludePatterns, err := t.patterns(v, path)
+		if err != nil {
+			return false, err
+		}
+		if len(includePatterns) > 0 {
+			if include, err = fileutils.NewPatternMatcher(includePatterns); err != nil {
+				return false, err
+			}
+		}
+		if exclude, err = fileutils.NewPatternMatcher(excludePatterns); err != nil {
+			return false, err
+		}
+	}
+
+	for _, file := range files {
+		rel, err := filepath.Rel(path, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// A file or a secret
+		if !plancontext.IsFSValue(contents) {
+			if rel == "." {
+				return true, nil
+			}
+			continue
+		}
+
+		rel = filepath.ToSlash(rel)
+		if include != nil {
+			if ok, err := include.MatchesOrParentMatches(rel); err != nil || !ok {
+				continue
+			}
+		}
+		if ok, err := exclude.MatchesOrParentMatches(rel); err == nil && ok {
+			continue
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
 func (t clientFilesystemReadTask) readSecret(pctx *plancontext.Context, path string) (*compiler.Value, error) {
 	contents, err := t.readString(path)
 	if err != nil {
@@ -167,3 +265,244 @@ func (t clientFilesystemReadTask) readString(path string) (string, error) {
 	}
 	return string(contents), nil
 }
+
+// gitignorePatterns walks the tree looking for .gitignore files, and returns
+// their patterns as exclude patterns relative to the root of the tree (as well
+// as .git directories). Patterns which can't be converted are replaced by the
+// paths they match.
+func (t clientFilesystemReadTask) gitignorePatterns(root string) ([]string, error) {
+	var (
+		rules []*gitignoreRule
+		// Ignored directories, not walked
+		ignoredDirs []string
+	)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var domain []string
+		if rel != "." {
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			rel = filepath.ToSlash(rel)
+			domain = strings.Split(rel, "/")
+
+			// Like git, the last matching pattern decides
+			ignored := false
+			for _, r := range rules {
+				switch r.match(rel, domain, d.IsDir()) {
+				case gitignore.Exclude:
+					ignored = true
+				case gitignore.Include:
+					ignored = false
+				}
+			}
+			if ignored {
+				// Like git, don't look for files to re-include in ignored directories
+				if d.IsDir() {
+					ignoredDirs = append(ignoredDirs, rel)
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		rs, err := t.readGitignore(filepath.Join(path, ".gitignore"), domain)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rs...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	excludes := []string{"**/.git"}
+	negations := false
+	for _, r := range rules {
+		negations = negations || r.negate
+
+		patterns := []string{r.exclude}
+		if r.exclude == "" {
+			patterns = []string{}
+			for _, m := range r.matches {
+				patterns = append(patterns, escapePattern(m))
+			}
+		}
+		for _, p := range patterns {
+			if r.negate {
+				p = "!" + p
+			}
+			excludes = append(excludes, p)
+		}
+	}
+
+	// Unlike git, exclude patterns re-include files in excluded directories
+	if negations {
+		for _, dir := range ignoredDirs {
+			excludes = append(excludes, escapePattern(dir))
+		}
+	}
+
+	return excludes, nil
+}
+
+func (t clientFilesystemReadTask) readGitignore(path string, domain []string) ([]*gitignoreRule, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*gitignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		rules = append(rules, newGitignoreRule(line, domain))
+	}
+
+	return rules, scanner.Err()
+}
+
+// gitignoreRule is a pattern of a .gitignore file
+type gitignoreRule struct {
+	pattern gitignore.Pattern
+	negate  bool
+
+	// Equivalent exclude pattern, relative to the root of the tree. If empty,
+	// the paths matched by the pattern are excluded instead.
+	exclude string
+
+	// Exclude patterns can't only match directories: those of gitignore
+	// patterns ending with a slash also match files, unless a file would
+	// match them
+	dirOnly bool
+	files   gitignore.Pattern
+
+	// Paths matched by the pattern, without their contents
+	matches []string
+}
+
+// newGitignoreRule parses a line of the .gitignore file of the directory
+// domain
+func newGitignoreRule(line string, domain []string) *gitignoreRule {
+	r := &gitignoreRule{
+		pattern: gitignore.ParsePattern(line, domain),
+	}
+
+	// Parsed like gitignore.ParsePattern
+	p := line
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	}
+	if !strings.HasSuffix(p, `\ `) {
+		p = strings.TrimRight(p, " ")
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = p[:len(p)-1]
+		r.files = gitignore.ParsePattern(p, domain)
+	}
+
+	r.exclude = gitignoreExclude(p, domain)
+	return r
+}
+
+// gitignoreExclude converts a gitignore pattern, without its negation and
+// trailing slash, to an exclude pattern relative to the root of the tree. It
+// returns an empty string if the pattern can't be converted.
+func gitignoreExclude(p string, domain []string) string {
+	// Exclude patterns are trimmed: escaped spaces would be lost
+	if p == "" || strings.HasSuffix(p, `\`) {
+		return ""
+	}
+
+	// Patterns without a slash match at any level below their directory
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if !anchored {
+		p = "**/" + p
+	}
+	if len(domain) > 0 {
+		p = escapePattern(strings.Join(domain, "/")) + "/" + p
+	}
+
+	// Negated character classes
+	p = strings.ReplaceAll(p, "[!", "[^")
+
+	if strings.TrimSpace(p) != p || filepath.ToSlash(filepath.Clean(p)) != p {
+		return ""
+	}
+	if _, err := filepath.Match(p, "."); err != nil {
+		return ""
+	}
+	return p
+}
+
+// match matches a path of the tree against the rule, and keeps track of the
+// paths it matches in case they're excluded instead of the pattern
+func (r *gitignoreRule) match(rel string, domain []string, isDir bool) gitignore.MatchResult {
+	m := r.pattern.Match(domain, isDir)
+
+	if r.dirOnly && !isDir && m == gitignore.NoMatch && r.files.Match(domain, false) != gitignore.NoMatch {
+		r.exclude = ""
+	}
+
+	if m != gitignore.NoMatch && (r.exclude == "" || r.dirOnly) {
+		// The contents of a path are matched along with it
+		if n := len(r.matches); n == 0 || !strings.HasPrefix(rel, r.matches[n-1]+"/") {
+			r.matches = append(r.matches, rel)
+		}
+	}
+
+	return m
+}
+
+// dockerignorePatterns returns the patterns of the .dockerignore file at the
+// root of the tree, if any.
+func (t clientFilesystemReadTask) dockerignorePatterns(root string) ([]string, error) {
+	f, err := os.Open(filepath.Join(root, ".dockerignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return dockerignore.ReadAll(f)
+}
+
+// escapePattern escapes the characters having a special meaning in exclude patterns
+func escapePattern(path string) string {
+	return patternEscaper.Replace(path)
+}
+
+var patternEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"?", `\?`,
+	"[", `\[`,
+)
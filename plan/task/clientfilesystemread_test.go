@@ -0,0 +1,80 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitignorePatterns(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "*.log\n!keep.log\nbuild/\n/dist\ndoc/*.tmp\n[!a]x.bak\n",
+		".git/HEAD":        "ref",
+		"a.log":            "",
+		"keep.log":         "",
+		"build/out":        "",
+		"dist/app":         "",
+		"dist/keep.log":    "",
+		"doc/a.tmp":        "",
+		"doc/a.txt":        "",
+		"ax.bak":           "",
+		"zx.bak":           "",
+		"sub/.gitignore":   "*.txt\n!keep.txt\n",
+		"sub/a.txt":        "",
+		"sub/keep.txt":     "",
+		"sub/keep.log":     "",
+		"sub/dist/app":     "",
+		"sub/build/nested": "",
+	})
+
+	patterns, err := clientFilesystemReadTask{}.gitignorePatterns(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"**/.git",
+		"**/*.log",
+		"!**/keep.log",
+		"**/build",
+		"dist",
+		"doc/*.tmp",
+		"**/[^a]x.bak",
+		"sub/**/*.txt",
+		"!sub/**/keep.txt",
+		// Not re-included by negations
+		"build",
+		"dist",
+		"sub/build",
+	}, patterns)
+
+	// A file matching a pattern only matching directories
+	writeTree(t, root, map[string]string{"sub/file/build": ""})
+	patterns, err = clientFilesystemReadTask{}.gitignorePatterns(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{"build", "sub/build"}, patterns[3:5])
+
+	matcher, err := fileutils.NewPatternMatcher(patterns)
+	require.NoError(t, err)
+	for file, excluded := range map[string]bool{
+		".git/HEAD":        true,
+		"a.log":            true,
+		"keep.log":         false,
+		"build/out":        true,
+		"dist/app":         true,
+		"dist/keep.log":    true,
+		"doc/a.tmp":        true,
+		"doc/a.txt":        false,
+		"ax.bak":           false,
+		"zx.bak":           true,
+		"sub/a.txt":        true,
+		"sub/keep.txt":     false,
+		"sub/keep.log":     false,
+		"sub/dist/app":     false,
+		"sub/build/nested": true,
+		"sub/file/build":   false,
+	} {
+		ok, err := matcher.MatchesOrParentMatches(file)
+		require.NoError(t, err)
+		require.Equal(t, excluded, ok, file)
+	}
+}
@@ -2,12 +2,16 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 
 	"cuelang.org/go/cue"
 	bk "github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
 	"github.com/rs/zerolog/log"
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/plancontext"
@@ -39,13 +43,28 @@ func (t clientFilesystemWriteTask) Run(ctx context.Context, pctx *plancontext.Co
 	return compiler.NewValue(), nil
 }
 
+// Fingerprint implements Memoizable, with the state of the written path
+func (t clientFilesystemWriteTask) Fingerprint(ctx context.Context, pctx *plancontext.Context, _ solver.Solver, v *compiler.Value) (string, error) {
+	path, err := v.Lookup("path").String()
+	if err != nil {
+		return "", err
+	}
+
+	path, err = clientFilePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	return FingerprintPath(ctx, path, nil)
+}
+
 func (t clientFilesystemWriteTask) writeContents(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value, path string) error {
 	lg := log.Ctx(ctx)
 	contents := v.Lookup("contents")
 
 	if plancontext.IsFSValue(contents) {
 		lg.Debug().Str("path", path).Msg("writing files to local directory")
-		return t.writeFS(ctx, pctx, s, contents, path)
+		return t.writeFS(ctx, pctx, s, v, path)
 	}
 
 	permissions := fs.FileMode(0644) // default permission
@@ -80,7 +99,12 @@ func (t clientFilesystemWriteTask) writeContents(ctx context.Context, pctx *plan
 }
 
 func (t clientFilesystemWriteTask) writeFS(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value, path string) error {
-	contents, err := pctx.FS.FromValue(v)
+	var opts writeFSOpts
+	if err := v.Decode(&opts); err != nil {
+		return err
+	}
+
+	contents, err := pctx.FS.FromValue(v.Lookup("contents"))
 	if err != nil {
 		return err
 	}
@@ -90,10 +114,381 @@ func (t clientFilesystemWriteTask) writeFS(ctx context.Context, pctx *plancontex
 		return err
 	}
 
-	_, err = s.Export(ctx, st, nil, bk.ExportEntry{
+	if !opts.staged() {
+		return t.export(ctx, pctx, s, st, path)
+	}
+
+	return opts.writeStaged(ctx, path, func(dir string) error {
+		return t.export(ctx, pctx, s, st, dir)
+	})
+}
+
+// writeStaged exports files to a staging directory with export, then moves
+// them to path. If the export fails, path is left untouched.
+func (o writeFSOpts) writeStaged(ctx context.Context, path string, export func(dir string) error) error {
+	lg := log.Ctx(ctx)
+
+	// Stage the export next to the target so that it can be renamed in place
+	staging, err := os.MkdirTemp(filepath.Dir(path), "."+filepath.Base(path)+".dagger-")
+	if err != nil {
+		return err
+	}
+	defer removeAll(staging)
+
+	lg.Debug().Str("path", staging).Msg("exporting files to staging directory")
+	if err := export(staging); err != nil {
+		return err
+	}
+
+	dirs, err := o.apply(staging)
+	if err != nil {
+		return err
+	}
+
+	// The staging directory is created with restricted permissions:
+	// keep the ones of the directory being replaced
+	rootMode := fs.FileMode(0755)
+	if o.Permissions.Dirs != nil {
+		rootMode = fs.FileMode(*o.Permissions.Dirs)
+	} else if fi, err := os.Stat(path); err == nil {
+		rootMode = fi.Mode().Perm()
+	}
+
+	if o.Atomic {
+		if !o.Prune {
+			// Carry over the files which are not part of the export
+			if err := mergeMissing(path, staging); err != nil {
+				return err
+			}
+		}
+		if err := o.applyDirs(staging, dirs); err != nil {
+			return err
+		}
+		if err := os.Chmod(staging, rootMode); err != nil {
+			return err
+		}
+		lg.Debug().Str("path", path).Msg("swapping staging directory")
+		return swapDir(staging, path)
+	}
+
+	if o.Prune {
+		lg.Debug().Str("path", path).Msg("pruning files missing from the filesystem")
+		if err := pruneMissing(path, staging); err != nil {
+			return err
+		}
+	}
+	if err := o.moveInto(staging, path); err != nil {
+		return err
+	}
+	if err := o.applyDirs(path, dirs); err != nil {
+		return err
+	}
+	return os.Chmod(path, rootMode)
+}
+
+func (t clientFilesystemWriteTask) export(ctx context.Context, pctx *plancontext.Context, s solver.Solver, st llb.State, path string) error {
+	_, err := s.Export(ctx, st, nil, bk.ExportEntry{
 		Type:      bk.ExporterLocal,
 		OutputDir: path,
 	}, pctx.Platform.Get())
 
 	return err
 }
+
+type writeFSOpts struct {
+	Atomic      bool
+	Prune       bool
+	Permissions struct {
+		Files *int64
+		Dirs  *int64
+	}
+	Owner *struct {
+		UID int `json:"uid"`
+		GID int `json:"gid"`
+	}
+}
+
+// staged returns whether the export must go through a staging directory
+func (o writeFSOpts) staged() bool {
+	return o.Atomic || o.Prune || o.Permissions.Files != nil || o.Permissions.Dirs != nil || o.Owner != nil
+}
+
+// apply overrides the ownership of the exported files, and the permissions of
+// the files which are not directories. It returns the directories under root,
+// whose permissions are set by applyDirs once nothing has to be written into
+// them anymore.
+func (o writeFSOpts) apply(root string) ([]string, error) {
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if o.Owner != nil {
+			if err := os.Lchown(p, o.Owner.UID, o.Owner.GID); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case d.IsDir() && p != root:
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, rel)
+		case d.Type().IsRegular() && o.Permissions.Files != nil:
+			return os.Chmod(p, fs.FileMode(*o.Permissions.Files))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// applyDirs overrides the permissions of dirs, relative to root. They are
+// applied deepest first, so that restrictive permissions (e.g. 0o500) don't
+// prevent reaching the directories below.
+func (o writeFSOpts) applyDirs(root string, dirs []string) error {
+	if o.Permissions.Dirs == nil {
+		return nil
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(filepath.Join(root, dirs[i]), fs.FileMode(*o.Permissions.Dirs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errExchangeUnsupported = errors.New("atomic exchange not supported")
+
+// swapDir replaces dst with src. If dst can't be replaced, it's left untouched.
+//
+// On Linux, both are exchanged atomically with renameat2(RENAME_EXCHANGE).
+// Elsewhere, or if the filesystem doesn't support it, dst is first renamed out
+// of the way: dst doesn't exist for a moment, and if the process dies at that
+// point, its previous contents are left next to it, in a `.old` directory.
+func swapDir(src, dst string) error {
+	err := exchangeDirs(src, dst)
+	switch {
+	case err == nil:
+		// src now holds the previous contents of dst
+		return removeAll(src)
+	case errors.Is(err, fs.ErrNotExist):
+		return os.Rename(src, dst)
+	case !errors.Is(err, errExchangeUnsupported):
+		return err
+	}
+
+	backup := src + ".old"
+
+	if err := os.Rename(dst, backup); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Rename(src, dst)
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		if rerr := os.Rename(backup, dst); rerr != nil {
+			return fmt.Errorf("%w (failed to restore %q: %s)", err, dst, rerr)
+		}
+		return err
+	}
+
+	return removeAll(backup)
+}
+
+// removeAll removes path and its contents, including directories without
+// write permissions
+func removeAll(path string) error {
+	if err := os.RemoveAll(path); err == nil {
+		return nil
+	}
+	_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			_ = os.Chmod(p, 0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+// mergeMissing links (or copies) the files of dst which don't exist in src into src
+func mergeMissing(dst, src string) error {
+	return filepath.WalkDir(dst, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dst && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(dst, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(src, rel)
+
+		// Files from the export take precedence, including over directories
+		if fi, err := os.Lstat(target); err == nil {
+			if d.IsDir() && !fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		if err := os.Link(p, target); err == nil {
+			return nil
+		}
+		return copyFile(p, target, info.Mode().Perm())
+	})
+}
+
+// pruneMissing removes the files of dst which don't exist in src
+func pruneMissing(dst, src string) error {
+	return filepath.WalkDir(dst, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dst && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if p == dst {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dst, p)
+		if err != nil {
+			return err
+		}
+
+		fi, err := os.Lstat(filepath.Join(src, rel))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		// Directories replaced by a file are removed along with their contents
+		if err == nil && (!d.IsDir() || fi.IsDir()) {
+			return nil
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// moveInto moves the files of src into dst, replacing existing ones
+func (o writeFSOpts) moveInto(src, dst string) error {
+	type mergedDir struct {
+		path string
+		mode fs.FileMode
+	}
+	merged := []mergedDir{}
+
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		existing, err := os.Lstat(target)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			if err := os.Rename(p, target); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.IsDir() || !existing.IsDir() {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := os.Rename(p, target); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Merge into the existing directory, which must be writable until its
+		// contents have been moved
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		merged = append(merged, mergedDir{path: target, mode: info.Mode().Perm()})
+		if err := os.Chmod(target, existing.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		if o.Owner != nil {
+			return os.Lchown(target, o.Owner.UID, o.Owner.GID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deepest first, like applyDirs
+	for i := len(merged) - 1; i >= 0; i-- {
+		if err := os.Chmod(merged[i].path, merged[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,20 @@
+//go:build linux
+// +build linux
+
+package task
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchangeDirs atomically swaps two existing paths
+func exchangeDirs(src, dst string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, src, unix.AT_FDCWD, dst, unix.RENAME_EXCHANGE)
+	// Not supported by the kernel or the filesystem
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EINVAL) {
+		return errExchangeUnsupported
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package task
+
+// exchangeDirs atomically swaps two existing paths
+func exchangeDirs(src, dst string) error {
+	return errExchangeUnsupported
+}
@@ -0,0 +1,150 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		p := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0600))
+	}
+}
+
+func readTree(t *testing.T, root string) map[string]string {
+	files := map[string]string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(contents)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func TestWriteStagedFailedExport(t *testing.T) {
+	existing := map[string]string{
+		"a":     "old a",
+		"sub/b": "old b",
+	}
+
+	for name, opts := range map[string]writeFSOpts{
+		"atomic": {Atomic: true},
+		"prune":  {Prune: true},
+		"atomic prune": {
+			Atomic: true,
+			Prune:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			path := filepath.Join(parent, "out")
+			writeTree(t, path, existing)
+
+			exportErr := errors.New("export failed")
+			err := opts.writeStaged(context.Background(), path, func(dir string) error {
+				// Partial export
+				writeTree(t, dir, map[string]string{"a": "new a"})
+				return exportErr
+			})
+			require.ErrorIs(t, err, exportErr)
+
+			require.Equal(t, existing, readTree(t, path))
+
+			// The staging directory is removed
+			entries, err := os.ReadDir(parent)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestWriteStaged(t *testing.T) {
+	dirs := int64(0500)
+	files := int64(0400)
+
+	for name, opts := range map[string]writeFSOpts{
+		"atomic": {Atomic: true},
+		"merge":  {},
+	} {
+		opts.Permissions.Dirs = &dirs
+		opts.Permissions.Files = &files
+
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out")
+			writeTree(t, path, map[string]string{
+				"a":     "old a",
+				"keep":  "keep",
+				"sub/b": "old b",
+			})
+
+			// Writing twice replaces directories with restrictive permissions
+			for i := 0; i < 2; i++ {
+				err := opts.writeStaged(context.Background(), path, func(dir string) error {
+					writeTree(t, dir, map[string]string{
+						"a":           "new a",
+						"sub/b":       "new b",
+						"sub/sub/c":   "new c",
+						"other/sub/d": "new d",
+					})
+					return nil
+				})
+				require.NoError(t, err)
+			}
+			defer removeAll(path)
+
+			require.Equal(t, map[string]string{
+				"a":           "new a",
+				"keep":        "keep",
+				"sub/b":       "new b",
+				"sub/sub/c":   "new c",
+				"other/sub/d": "new d",
+			}, readTree(t, path))
+
+			for _, dir := range []string{".", "sub", "sub/sub", "other", "other/sub"} {
+				fi, err := os.Stat(filepath.Join(path, dir))
+				require.NoError(t, err)
+				require.Equal(t, fs.FileMode(dirs), fi.Mode().Perm(), dir)
+			}
+			fi, err := os.Stat(filepath.Join(path, "sub/sub/c"))
+			require.NoError(t, err)
+			require.Equal(t, fs.FileMode(files), fi.Mode().Perm())
+		})
+	}
+}
+
+func TestSwapDir(t *testing.T) {
+	parent := t.TempDir()
+	src := filepath.Join(parent, "src")
+	dst := filepath.Join(parent, "dst")
+	writeTree(t, src, map[string]string{"new": "new"})
+	writeTree(t, dst, map[string]string{"old": "old"})
+
+	require.NoError(t, swapDir(src, dst))
+	require.Equal(t, map[string]string{"new": "new"}, readTree(t, dst))
+	_, err := os.Stat(src)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// dst doesn't exist yet
+	missing := filepath.Join(parent, "missing")
+	require.NoError(t, swapDir(dst, missing))
+	require.Equal(t, map[string]string{"new": "new"}, readTree(t, missing))
+}
@@ -33,32 +33,58 @@ func (t clientNetwork) Run(ctx context.Context, pctx *plancontext.Context, s sol
 		return nil, err
 	}
 
-	lg.Debug().Str("type", u.Scheme).Str("path", u.Path).Msg("loading local socket")
-
-	if _, err := os.Stat(u.Path); errors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("path %q does not exist", u.Path)
-	}
-
-	var unix, npipe string
+	var unix, npipe, tcp string
 
 	switch u.Scheme {
 	case "unix":
 		unix = u.Path
 	case "npipe":
 		npipe = u.Path
+	case "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("missing port in address %q", addr)
+		}
+		tcp = u.Host
 	default:
 		return nil, fmt.Errorf("invalid service type %q", u.Scheme)
 	}
 
-	connect := v.Lookup("connect")
+	if connect := v.Lookup("connect"); connect.Exists() {
+		if !plancontext.IsServiceValue(connect) {
+			return nil, fmt.Errorf("wrong type %q", connect.Kind())
+		}
+
+		lg.Debug().Str("type", u.Scheme).Str("address", addr).Msg("loading local socket")
+
+		if tcp == "" {
+			if _, err := os.Stat(u.Path); errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("path %q does not exist", u.Path)
+			}
+		}
+
+		service := pctx.Services.New(unix, npipe, tcp)
+
+		return compiler.NewValue().FillFields(map[string]interface{}{
+			"connect": service.MarshalCUE(),
+		})
+	}
+
+	listen := v.Lookup("listen")
+	if !plancontext.IsServiceValue(listen) {
+		return nil, fmt.Errorf("wrong type %q", listen.Kind())
+	}
+
+	lg.Debug().Str("type", u.Scheme).Str("address", addr).Msg("listening on local socket")
 
-	if !plancontext.IsServiceValue(connect) {
-		return nil, fmt.Errorf("wrong type %q", connect.Kind())
+	l, err := listenService(unix, npipe, tcp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", addr, err)
 	}
 
-	service := pctx.Services.New(unix, npipe)
+	// The listener is closed once the plan is done executing
+	service := pctx.Services.Listen(ctx, l, unix, npipe, tcp)
 
 	return compiler.NewValue().FillFields(map[string]interface{}{
-		"connect": service.MarshalCUE(),
+		"listen": service.MarshalCUE(),
 	})
 }
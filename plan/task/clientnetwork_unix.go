@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package task
+
+import (
+	"errors"
+	"net"
+)
+
+func listenService(unix, _, tcp string) (net.Listener, error) {
+	switch {
+	case unix != "":
+		return net.Listen("unix", unix)
+	case tcp != "":
+		return net.Listen("tcp", tcp)
+	default:
+		return nil, errors.New("unsupported socket type")
+	}
+}
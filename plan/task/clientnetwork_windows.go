@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package task
+
+import (
+	"errors"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+)
+
+func listenService(_, npipe, tcp string) (net.Listener, error) {
+	switch {
+	case npipe != "":
+		return winio.ListenPipe(npipe, nil)
+	case tcp != "":
+		return net.Listen("tcp", tcp)
+	default:
+		return nil, errors.New("unsupported socket type")
+	}
+}
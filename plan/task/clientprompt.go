@@ -0,0 +1,258 @@
+package task
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"github.com/rs/zerolog/log"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/solver"
+	"golang.org/x/term"
+)
+
+func init() {
+	Register("ClientPrompt", func() Task { return &clientPromptTask{} })
+}
+
+var (
+	// Only prompt the user for one thing at a time
+	promptLock sync.Mutex
+
+	// Buffered reader shared by all prompts, so that input typed ahead is not lost
+	promptReader = bufio.NewReader(os.Stdin)
+)
+
+// Pauser is implemented by log outputs redrawing the terminal, which must stop
+// while the user is prompted.
+type Pauser interface {
+	Pause()
+	Resume()
+}
+
+type pauserKey struct{}
+
+// WithPauser returns a context holding the log output to pause during prompts.
+func WithPauser(ctx context.Context, p Pauser) context.Context {
+	return context.WithValue(ctx, pauserKey{}, p)
+}
+
+type clientPromptTask struct {
+}
+
+func (t clientPromptTask) Run(ctx context.Context, pctx *plancontext.Context, _ solver.Solver, v *compiler.Value) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
+
+	message, err := v.Lookup("message").String()
+	if err != nil {
+		return nil, err
+	}
+
+	value := v.Lookup("value")
+	def := v.Lookup("default")
+
+	// Resolve default in disjunction if a type hasn't been specified
+	val, _ := value.Default()
+
+	var options []string
+	if o := v.Lookup("options"); o.Exists() {
+		if err := o.Decode(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if !def.Exists() {
+			return nil, fmt.Errorf("cannot prompt for %q: not running in an interactive terminal", message)
+		}
+		lg.Debug().Msg("not running in an interactive terminal, using default")
+		if val.IncompleteKind() == cue.StringKind && !plancontext.IsSecretValue(val) && len(options) > 0 {
+			d, err := def.String()
+			if err != nil {
+				return nil, err
+			}
+			option, ok := t.matchOption(d, options)
+			if !ok {
+				return nil, fmt.Errorf("cannot prompt for %q: default %q is not one of the options %q", message, d, options)
+			}
+			return t.fill(option)
+		}
+		return t.fillDefault(pctx, val, def)
+	}
+
+	promptLock.Lock()
+	defer promptLock.Unlock()
+
+	if p, ok := ctx.Value(pauserKey{}).(Pauser); ok {
+		p.Pause()
+		defer p.Resume()
+	}
+
+	switch {
+	case plancontext.IsSecretValue(val):
+		lg.Debug().Str("type", "secret").Msg("prompting user")
+		answer, err := t.promptSecret(message)
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" && def.Exists() {
+			return t.fillDefault(pctx, val, def)
+		}
+		return t.fill(pctx.Secrets.New(answer).MarshalCUE())
+	case val.IncompleteKind() == cue.BoolKind:
+		lg.Debug().Str("type", "bool").Msg("prompting user")
+		answer, err := t.promptConfirm(promptReader, os.Stderr, message, def)
+		if err != nil {
+			return nil, err
+		}
+		return t.fill(answer)
+	case val.IncompleteKind() == cue.StringKind:
+		lg.Debug().Str("type", "string").Msg("prompting user")
+		answer, err := t.promptString(promptReader, os.Stderr, message, options, def)
+		if err != nil {
+			return nil, err
+		}
+		return t.fill(answer)
+	}
+
+	return nil, fmt.Errorf("unsupported type %q", val.IncompleteKind())
+}
+
+func (t clientPromptTask) fill(value interface{}) (*compiler.Value, error) {
+	return compiler.NewValue().FillFields(map[string]interface{}{
+		"value": value,
+	})
+}
+
+func (t clientPromptTask) fillDefault(pctx *plancontext.Context, val, def *compiler.Value) (*compiler.Value, error) {
+	if val.IncompleteKind() == cue.BoolKind {
+		b, err := def.Bool()
+		if err != nil {
+			return nil, err
+		}
+		return t.fill(b)
+	}
+
+	s, err := def.String()
+	if err != nil {
+		return nil, err
+	}
+	if plancontext.IsSecretValue(val) {
+		return t.fill(pctx.Secrets.New(s).MarshalCUE())
+	}
+	return t.fill(s)
+}
+
+func (t clientPromptTask) promptSecret(message string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", message)
+	defer fmt.Fprintln(os.Stderr)
+
+	answer, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+	return string(answer), nil
+}
+
+func (t clientPromptTask) promptConfirm(r *bufio.Reader, w io.Writer, message string, def *compiler.Value) (bool, error) {
+	hint := "y/n"
+	if def.Exists() {
+		d, err := def.Bool()
+		if err != nil {
+			return false, err
+		}
+		hint = "y/N"
+		if d {
+			hint = "Y/n"
+		}
+	}
+
+	for {
+		fmt.Fprintf(w, "%s [%s]: ", message, hint)
+		answer, err := t.readLine(r)
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "":
+			if def.Exists() {
+				return def.Bool()
+			}
+		}
+		fmt.Fprintln(w, "Please answer yes or no.")
+	}
+}
+
+func (t clientPromptTask) promptString(r *bufio.Reader, w io.Writer, message string, options []string, def *compiler.Value) (string, error) {
+	var d string
+	if def.Exists() {
+		var err error
+		d, err = def.String()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for i, option := range options {
+		fmt.Fprintf(w, "  %d) %s\n", i+1, option)
+	}
+
+	for {
+		if def.Exists() {
+			fmt.Fprintf(w, "%s [%s]: ", message, d)
+		} else {
+			fmt.Fprintf(w, "%s: ", message)
+		}
+
+		answer, err := t.readLine(r)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" && def.Exists() {
+			answer = d
+		}
+
+		if len(options) == 0 {
+			return answer, nil
+		}
+		if option, ok := t.matchOption(answer, options); ok {
+			return option, nil
+		}
+		fmt.Fprintf(w, "Please choose one of the options (1-%d).\n", len(options))
+	}
+}
+
+// matchOption returns the option chosen by an answer, either the option
+// itself or its number
+func (t clientPromptTask) matchOption(answer string, options []string) (string, bool) {
+	for i, option := range options {
+		if answer == option || answer == strconv.Itoa(i+1) {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+func (t clientPromptTask) readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no input from the user")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
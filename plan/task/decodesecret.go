@@ -4,12 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"cuelang.org/go/cue"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml"
 	"github.com/rs/zerolog/log"
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/plancontext"
 	"go.dagger.io/dagger/solver"
+	"gopkg.in/ini.v1"
 	"gopkg.in/yaml.v3"
 )
 
@@ -38,45 +44,181 @@ func (c *decodeSecretTask) Run(ctx context.Context, pctx *plancontext.Context, _
 
 	lg.Debug().Str("format", format).Msg("unmarshaling secret")
 
-	inputSecretPlaintext := inputSecret.PlainText()
+	unmarshaled, err := decodeSecret(format, inputSecret.PlainText())
+	if err != nil {
+		// returning err here could expose secret plaintext!
+		return nil, errors.New("could not unmarshal secret")
+	}
+
+	return secretTree(ctx, pctx, format, unmarshaled)
+}
 
-	var unmarshaled map[string]interface{}
+// decodeSecret unmarshals the plain text of a secret in one of the formats of
+// #DecodeSecret
+func decodeSecret(format, plaintext string) (map[string]interface{}, error) {
+	var (
+		unmarshaled map[string]interface{}
+		err         error
+	)
 
 	switch format {
 	case "json":
-		err = json.Unmarshal([]byte(inputSecretPlaintext), &unmarshaled)
+		err = json.Unmarshal([]byte(plaintext), &unmarshaled)
 	case "yaml":
-		err = yaml.Unmarshal([]byte(inputSecretPlaintext), &unmarshaled)
+		err = yaml.Unmarshal([]byte(plaintext), &unmarshaled)
+	case "dotenv":
+		unmarshaled, err = decodeDotenv(plaintext)
+	case "ini":
+		unmarshaled, err = decodeINI(plaintext)
+	case "toml":
+		var tree *toml.Tree
+		tree, err = toml.Load(plaintext)
+		if err == nil {
+			unmarshaled = tree.ToMap()
+		}
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
 	}
 
+	return unmarshaled, err
+}
+
+func decodeDotenv(s string) (map[string]interface{}, error) {
+	envs, err := godotenv.Unmarshal(s)
 	if err != nil {
-		// returning err here could expose secret plaintext!
-		return nil, errors.New("could not unmarshal secret")
+		return nil, err
+	}
+
+	unmarshaled := make(map[string]interface{}, len(envs))
+	for k, v := range envs {
+		unmarshaled[k] = v
+	}
+	return unmarshaled, nil
+}
+
+// decodeINI returns the keys of each section, nested under the section name.
+// Keys outside of any section are at the top level.
+func decodeINI(s string) (map[string]interface{}, error) {
+	cfg, err := ini.Load([]byte(s))
+	if err != nil {
+		return nil, err
 	}
 
+	unmarshaled := make(map[string]interface{})
+	for _, section := range cfg.Sections() {
+		keys := unmarshaled
+		if section.Name() != ini.DefaultSection {
+			keys = make(map[string]interface{})
+			unmarshaled[section.Name()] = keys
+		}
+
+		for _, key := range section.Keys() {
+			keys[key.Name()] = key.Value()
+		}
+	}
+	return unmarshaled, nil
+}
+
+// secretTree fills `output` with a secret for each value of the tree,
+// preserving its structure. Numbers, booleans and dates are converted to their
+// string representation.
+//
+// JSON and YAML documents are decoded as they always were: dotted keys are
+// nested (`a.b` is `output.a.b`) and lists are skipped. Keys of the other
+// formats are used as is, and lists fail the decoding.
+func secretTree(ctx context.Context, pctx *plancontext.Context, format string, tree map[string]interface{}) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
 	output := compiler.NewValue()
+	legacy := format == "json" || format == "yaml"
 
-	// recurse over unmarshaled to convert string values to secrets
-	var convert func(p []cue.Selector, i interface{})
-	convert = func(p []cue.Selector, i interface{}) {
-		switch entry := i.(type) {
-		case string:
-			secret := pctx.Secrets.New(entry)
-			p = append(p, cue.ParsePath("contents").Selectors()...)
-			logPath := cue.MakePath(p[1 : len(p)-1]...)
-			lg.Debug().Str("path", logPath.String()).Str("type", "string").Msg("found secret")
-			path := cue.MakePath(p...)
-			output.FillPath(path, secret.MarshalCUE())
-		case map[string]interface{}:
+	// recurse over unmarshaled to convert values to secrets
+	var convert func(p []cue.Selector, i interface{}) error
+	convert = func(p []cue.Selector, i interface{}) error {
+		if entry, ok := i.(map[string]interface{}); ok {
 			for k, v := range entry {
 				np := append([]cue.Selector{}, p...)
-				np = append(np, cue.ParsePath(k).Selectors()...)
-				convert(np, v)
+				if key, ok := dottedKey(k); legacy && ok {
+					np = append(np, key...)
+				} else {
+					// Keys may not be valid identifiers (e.g. `[profile dev]` in INI files)
+					np = append(np, cue.Str(k))
+				}
+				if err := convert(np, v); err != nil {
+					return err
+				}
 			}
+			return nil
 		}
+
+		logPath := cue.MakePath(p[1:]...)
+		plaintext, typ, ok := secretString(i)
+		if !ok {
+			// Only the type of the value is reported, not to expose it
+			typ := fmt.Sprintf("%T", i)
+			if _, ok := i.([]interface{}); ok {
+				typ = "list"
+			}
+			if legacy {
+				lg.Debug().Str("path", logPath.String()).Str("type", typ).Msg("skipping value")
+				return nil
+			}
+			return fmt.Errorf("unsupported value at %s: %s values can't be decoded as secrets", logPath, typ)
+		}
+
+		secret := pctx.Secrets.New(plaintext)
+		lg.Debug().Str("path", logPath.String()).Str("type", typ).Msg("found secret")
+		path := cue.MakePath(append(p, cue.Str("contents"))...)
+		return output.FillPath(path, secret.MarshalCUE())
 	}
 
-	convert(cue.ParsePath("output").Selectors(), unmarshaled)
+	if err := convert(cue.ParsePath("output").Selectors(), tree); err != nil {
+		return nil, err
+	}
 
 	return output, nil
 }
+
+// dottedKey returns the labels of a dotted key (e.g. `db.password`), if each
+// of them is a valid field name
+func dottedKey(k string) ([]cue.Selector, bool) {
+	key := cue.ParsePath(k)
+	if key.Err() != nil {
+		return nil, false
+	}
+	sels := key.Selectors()
+	for _, sel := range sels {
+		if !sel.IsString() {
+			return nil, false
+		}
+	}
+	return sels, len(sels) > 0
+}
+
+// secretString returns the plain text of a scalar value, and its type
+func secretString(i interface{}) (string, string, bool) {
+	switch v := i.(type) {
+	case string:
+		return v, "string", true
+	case bool:
+		return strconv.FormatBool(v), "bool", true
+	case int:
+		return strconv.Itoa(v), "int", true
+	case int64:
+		return strconv.FormatInt(v, 10), "int", true
+	case uint64:
+		return strconv.FormatUint(v, 10), "int", true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), "float", true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), "datetime", true
+	case toml.LocalDate:
+		return v.String(), "date", true
+	case toml.LocalTime:
+		return v.String(), "time", true
+	case toml.LocalDateTime:
+		return v.String(), "datetime", true
+	case nil:
+		return "", "null", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,55 @@
+package task
+
+import (
+	"context"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/require"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plancontext"
+)
+
+func TestSecretTree(t *testing.T) {
+	ctx := context.Background()
+
+	plaintext := func(pctx *plancontext.Context, v *compiler.Value, path string) string {
+		s, err := pctx.Secrets.FromValue(v.LookupPath(cue.ParsePath(path)))
+		require.NoError(t, err)
+		return s.PlainText()
+	}
+
+	// JSON and YAML nest dotted keys and skip lists
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			pctx := plancontext.New()
+			tree, err := decodeSecret(format, `{"db.password": "secret", "port": 5432, "hosts": ["a", "b"], "profile dev": {"token": "dev"}}`)
+			require.NoError(t, err)
+
+			v, err := secretTree(ctx, pctx, format, tree)
+			require.NoError(t, err)
+			require.Equal(t, "secret", plaintext(pctx, v, "output.db.password.contents"))
+			require.Equal(t, "5432", plaintext(pctx, v, "output.port.contents"))
+			require.Equal(t, "dev", plaintext(pctx, v, `output."profile dev".token.contents`))
+			require.False(t, v.LookupPath(cue.ParsePath("output.hosts")).Exists())
+		})
+	}
+
+	// Other formats keep keys as is and fail on lists
+	pctx := plancontext.New()
+	tree, err := decodeSecret("toml", "\"db.password\" = \"secret\"\nat = 2022-04-01T10:00:00\n")
+	require.NoError(t, err)
+	v, err := secretTree(ctx, pctx, "toml", tree)
+	require.NoError(t, err)
+	require.Equal(t, "secret", plaintext(pctx, v, `output."db.password".contents`))
+	require.Equal(t, "2022-04-01T10:00:00", plaintext(pctx, v, "output.at.contents"))
+
+	tree, err = decodeSecret("toml", "[db]\nhosts = [\"a\", \"b\"]\n")
+	require.NoError(t, err)
+	_, err = secretTree(ctx, pctx, "toml", tree)
+	require.EqualError(t, err, "unsupported value at db.hosts: list values can't be decoded as secrets")
+
+	// Unknown types aren't converted
+	_, err = secretTree(ctx, pctx, "toml", map[string]interface{}{"value": struct{}{}})
+	require.EqualError(t, err, "unsupported value at value: struct {} values can't be decoded as secrets")
+}
@@ -0,0 +1,247 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/rs/zerolog/log"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/solver"
+	"go.mozilla.org/sops/v3"
+	sopsaes "go.mozilla.org/sops/v3/aes"
+	"go.mozilla.org/sops/v3/keyservice"
+	sopsjson "go.mozilla.org/sops/v3/stores/json"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	Register("DecryptSecret", func() Task { return &decryptSecretTask{} })
+}
+
+type decryptSecretTask struct {
+}
+
+func (t *decryptSecretTask) Run(ctx context.Context, pctx *plancontext.Context, _ solver.Solver, v *compiler.Value) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
+	lg.Debug().Msg("decrypting secret")
+
+	input, err := t.getString(pctx, v.Lookup("input"))
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := v.Lookup("format").String()
+	if err != nil {
+		return nil, err
+	}
+
+	var store sops.Store
+	switch format {
+	case "json":
+		store = &sopsjson.Store{}
+	case "yaml":
+		store = &sopsyaml.Store{}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	tree, err := store.LoadEncryptedFile([]byte(input))
+	if err != nil {
+		// returning err here could expose secret plaintext!
+		return nil, fmt.Errorf("could not unmarshal %s secret", format)
+	}
+
+	keys, err := t.keyService(pctx, v.Lookup("keys"))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := tree.Metadata.GetDataKeyWithKeyServices([]keyservice.KeyServiceClient{keys})
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt secret with the provided keys: %w", err)
+	}
+
+	cipher := sopsaes.NewCipher()
+	mac, err := tree.Decrypt(key, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt secret: %w", err)
+	}
+
+	// The MAC of the decrypted values must match the one of the document
+	originalMAC, err := cipher.Decrypt(
+		tree.Metadata.MessageAuthenticationCode,
+		key,
+		tree.Metadata.LastModified.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify secret: %w", err)
+	}
+	if originalMAC != mac {
+		return nil, errors.New("could not verify secret: MAC mismatch, the file may have been tampered with")
+	}
+
+	plaintext, err := store.EmitPlainFile(tree.Branches)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s secret", format)
+	}
+
+	decoded, err := decodeSecret(format, string(plaintext))
+	if err != nil {
+		// returning err here could expose secret plaintext!
+		return nil, fmt.Errorf("could not unmarshal %s secret", format)
+	}
+
+	return secretTree(ctx, pctx, format, decoded)
+}
+
+func (t *decryptSecretTask) getString(pctx *plancontext.Context, v *compiler.Value) (string, error) {
+	if plancontext.IsSecretValue(v) {
+		secret, err := pctx.Secrets.FromValue(v)
+		if err != nil {
+			return "", err
+		}
+		return secret.PlainText(), nil
+	}
+
+	return v.String()
+}
+
+// keyService returns a sops key service decrypting data keys with the keys of
+// the task, rather than the ones of the sops configuration of the client
+func (t *decryptSecretTask) keyService(pctx *plancontext.Context, keys *compiler.Value) (*sopsKeyService, error) {
+	secret := func(name string) (string, error) {
+		v := keys.Lookup(name)
+		if !v.Exists() {
+			return "", nil
+		}
+		s, err := pctx.Secrets.FromValue(v)
+		if err != nil {
+			return "", err
+		}
+		return s.PlainText(), nil
+	}
+
+	ageKeys, err := secret("age")
+	if err != nil {
+		return nil, err
+	}
+	pgpKey, err := secret("pgp")
+	if err != nil {
+		return nil, err
+	}
+	pgpPassphrase, err := secret("pgpPassphrase")
+	if err != nil {
+		return nil, err
+	}
+
+	if ageKeys == "" && pgpKey == "" {
+		return nil, errors.New("no key provided to decrypt the secret")
+	}
+
+	s := &sopsKeyService{
+		pgpPassphrase: pgpPassphrase,
+	}
+	if ageKeys != "" {
+		s.age, err = age.ParseIdentities(strings.NewReader(ageKeys))
+		if err != nil {
+			return nil, errors.New("failed to parse age identities")
+		}
+	}
+	if pgpKey != "" {
+		s.pgp, err = openpgp.ReadArmoredKeyRing(strings.NewReader(pgpKey))
+		if err != nil {
+			return nil, errors.New("failed to parse pgp private key")
+		}
+	}
+	return s, nil
+}
+
+// sopsKeyService decrypts the data key of sops documents with age identities
+// and PGP keys held in memory
+type sopsKeyService struct {
+	age           []age.Identity
+	pgp           openpgp.EntityList
+	pgpPassphrase string
+}
+
+func (s *sopsKeyService) Encrypt(_ context.Context, _ *keyservice.EncryptRequest, _ ...grpc.CallOption) (*keyservice.EncryptResponse, error) {
+	return nil, errors.New("encryption is not supported")
+}
+
+func (s *sopsKeyService) Decrypt(_ context.Context, req *keyservice.DecryptRequest, _ ...grpc.CallOption) (*keyservice.DecryptResponse, error) {
+	var (
+		plaintext []byte
+		err       error
+	)
+
+	switch req.Key.KeyType.(type) {
+	case *keyservice.Key_AgeKey:
+		if len(s.age) == 0 {
+			return nil, errors.New("no age identity provided")
+		}
+		plaintext, err = s.decryptAge(req.Ciphertext)
+	case *keyservice.Key_PgpKey:
+		if len(s.pgp) == 0 {
+			return nil, errors.New("no pgp key provided")
+		}
+		plaintext, err = s.decryptPGP(req.Ciphertext)
+	default:
+		return nil, errors.New("unsupported master key type")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+func (s *sopsKeyService) decryptAge(enc []byte) ([]byte, error) {
+	r, err := age.Decrypt(agearmor.NewReader(bytes.NewReader(enc)), s.age...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func (s *sopsKeyService) decryptPGP(enc []byte) ([]byte, error) {
+	block, err := pgparmor.Decode(bytes.NewReader(enc))
+	if err != nil {
+		return nil, err
+	}
+
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if s.pgpPassphrase == "" {
+			return nil, errors.New("private key is encrypted: missing passphrase")
+		}
+		if prompted {
+			return nil, errors.New("invalid passphrase")
+		}
+		prompted = true
+
+		for _, k := range keys {
+			if k.PrivateKey != nil && k.PrivateKey.Encrypted {
+				// Keys not matching the passphrase are skipped
+				k.PrivateKey.Decrypt([]byte(s.pgpPassphrase))
+			}
+		}
+		return nil, nil
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, s.pgp, prompt, nil)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(md.UnverifiedBody)
+}
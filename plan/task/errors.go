@@ -0,0 +1,57 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// Error is the failure of a task. It's logged as an object with the details of
+// the failure.
+type Error struct {
+	// Path of the task, e.g. `actions.build`
+	Path string
+
+	// Type of the task, e.g. `Exec`
+	Type string
+
+	// CUE source position of the task
+	Pos string
+
+	// Failing command, if the task runs one
+	Args []string
+
+	// Exit code of the command, -1 if unknown
+	ExitCode int
+
+	// Last lines of output of the command
+	Logs []string
+
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) MarshalZerologObject(ev *zerolog.Event) {
+	ev.Str("message", e.Error()).
+		Str("task", e.Path).
+		Str("type", e.Type)
+
+	if e.Pos != "" {
+		ev.Str("pos", e.Pos)
+	}
+
+	if e.Args != nil {
+		ev.Strs("args", e.Args)
+		if e.ExitCode >= 0 {
+			ev.Int("exitCode", e.ExitCode)
+		}
+		ev.Strs("logs", e.Logs)
+	}
+}
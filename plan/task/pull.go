@@ -49,11 +49,6 @@ func (c *pullTask) Run(ctx context.Context, pctx *plancontext.Context, s solver.
 	// Add the default tag "latest" to a reference if it only has a repo name.
 	ref = reference.TagNameOnly(ref)
 
-	st := llb.Image(
-		ref.String(),
-		withCustomName(v, "Pull %s", rawRef),
-	)
-
 	// Load image metadata and convert to to LLB.
 	platform := pctx.Platform.Get()
 	image, digest, err := s.ResolveImageConfig(ctx, ref.String(), llb.ResolveImageConfigOpt{
@@ -64,6 +59,19 @@ func (c *pullTask) Run(ctx context.Context, pctx *plancontext.Context, s solver.
 		return nil, err
 	}
 
+	// Pull the resolved image, so that the filesystem is identified by its
+	// contents rather than its tag
+	if _, ok := ref.(reference.Canonical); !ok && digest != "" {
+		if ref, err = reference.WithDigest(ref, digest); err != nil {
+			return nil, err
+		}
+	}
+
+	st := llb.Image(
+		ref.String(),
+		withCustomName(v, "Pull %s", rawRef),
+	)
+
 	result, err := s.Solve(ctx, st, pctx.Platform.Get())
 	if err != nil {
 		return nil, err
@@ -6,6 +6,7 @@ import (
 
 	"github.com/docker/distribution/reference"
 	bk "github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
 	"github.com/rs/zerolog/log"
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/plancontext"
@@ -19,7 +20,29 @@ func init() {
 type pushTask struct {
 }
 
-func (c *pushTask) Run(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value) (*compiler.Value, error) {
+// Fingerprint implements Memoizable: the digest currently pointed to by the
+// tag, so that the image is pushed again if the tag was moved or deleted
+func (c *pushTask) Fingerprint(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value) (string, error) {
+	dest, err := c.dest(ctx, pctx, s, v)
+	if err != nil {
+		return "", err
+	}
+
+	platform := pctx.Platform.Get()
+	_, digest, err := s.ResolveImageConfig(ctx, dest.String(), llb.ResolveImageConfigOpt{
+		Platform:    &platform,
+		ResolveMode: llb.ResolveModeForcePull.String(),
+	})
+	if err != nil {
+		// The tag doesn't exist (yet), or can't be resolved: push again
+		log.Ctx(ctx).Debug().Err(err).Str("dest", dest.String()).Msg("failed to resolve pushed tag")
+		return "", nil
+	}
+	return digest.String(), nil
+}
+
+// dest returns the reference to push to, and registers its credentials
+func (c *pushTask) dest(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value) (reference.Named, error) {
 	lg := log.Ctx(ctx)
 
 	rawDest, err := v.Lookup("dest").String()
@@ -49,6 +72,16 @@ func (c *pushTask) Run(ctx context.Context, pctx *plancontext.Context, s solver.
 		s.AddCredentials(target, a.Username, a.Secret.PlainText())
 		lg.Debug().Str("target", target).Msg("add target credentials")
 	}
+	return dest, nil
+}
+
+func (c *pushTask) Run(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
+
+	dest, err := c.dest(ctx, pctx, s, v)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get input state
 	input, err := pctx.FS.FromValue(v.Lookup("input"))
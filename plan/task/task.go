@@ -31,6 +31,7 @@ var (
 type State string
 
 const (
+	StateQueued    = State("queued")
 	StateComputing = State("computing")
 	StateCanceled  = State("canceled")
 	StateFailed    = State("failed")
@@ -49,6 +50,28 @@ type PreRunner interface {
 	PreRun(ctx context.Context, pctx *plancontext.Context, v *compiler.Value) error
 }
 
+// Memoizable is a task whose result can be reused when its inputs didn't change
+// since its last successful run (see `dagger do --memoize`)
+type Memoizable interface {
+	Task
+
+	// Fingerprint returns the state of the resources written by the task
+	// outside of buildkit, such as client files or registry tags. A recorded
+	// result is only reused if it didn't change.
+	Fingerprint(ctx context.Context, pctx *plancontext.Context, s solver.Solver, v *compiler.Value) (string, error)
+}
+
+// ClientReader is a task reading files on the client machine. With
+// `dagger do --changed-since`, only the actions depending on a task which reads
+// changed files run.
+type ClientReader interface {
+	Task
+
+	// ReadsAny returns whether the task reads one of the given client files,
+	// by absolute path
+	ReadsAny(pctx *plancontext.Context, v *compiler.Value, files []string) (bool, error)
+}
+
 // Register a task type and initializer
 func Register(typ string, f NewFunc) {
 	tasks.Store(typ, f)
@@ -82,6 +105,11 @@ func Lookup(v *compiler.Value) (Task, error) {
 	return t, nil
 }
 
+// TypeOf returns the type of the task defined by a value (e.g. `Exec`)
+func TypeOf(v *compiler.Value) (string, error) {
+	return lookupType(v)
+}
+
 func lookupType(v *compiler.Value) (string, error) {
 	for _, path := range paths {
 		typ := v.LookupPath(path)
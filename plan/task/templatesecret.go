@@ -0,0 +1,122 @@
+package task
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"cuelang.org/go/cue"
+	"github.com/rs/zerolog/log"
+	"go.dagger.io/dagger/compiler"
+	"go.dagger.io/dagger/plancontext"
+	"go.dagger.io/dagger/solver"
+)
+
+func init() {
+	Register("TemplateSecret", func() Task { return &templateSecretTask{} })
+}
+
+// Functions available in templates, e.g. to build a docker `auth` field
+var templateSecretFuncs = template.FuncMap{
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"b64dec": func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return string(b), err
+	},
+	"trim": strings.TrimSpace,
+	"toJson": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type templateSecretTask struct {
+}
+
+func (c *templateSecretTask) Run(ctx context.Context, pctx *plancontext.Context, _ solver.Solver, v *compiler.Value) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
+	lg.Debug().Msg("rendering secret template")
+
+	text, err := v.Lookup("template").String()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("secret").
+		Option("missingkey=error").
+		Funcs(templateSecretFuncs).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	data, err := c.data(pctx, v.Lookup("inputs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	secret := pctx.Secrets.New(rendered.String())
+	return compiler.NewValue().FillFields(map[string]interface{}{
+		"output": secret.MarshalCUE(),
+	})
+}
+
+// data converts the inputs of the template, replacing secrets with their plain text
+func (c *templateSecretTask) data(pctx *plancontext.Context, v *compiler.Value) (interface{}, error) {
+	if plancontext.IsSecretValue(v) {
+		secret, err := pctx.Secrets.FromValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return secret.PlainText(), nil
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		fields, err := v.Fields()
+		if err != nil {
+			return nil, err
+		}
+
+		data := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			value, err := c.data(pctx, field.Value)
+			if err != nil {
+				return nil, err
+			}
+			data[field.Label()] = value
+		}
+		return data, nil
+	case cue.ListKind:
+		items, err := v.List()
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			value, err := c.data(pctx, item)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value)
+		}
+		return data, nil
+	}
+
+	var data interface{}
+	if err := v.Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -1,11 +1,16 @@
 package task
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/moby/buildkit/client/llb"
+	"github.com/tonistiigi/fsutil"
 	"go.dagger.io/dagger/compiler"
 )
 
@@ -26,3 +31,33 @@ func clientFilePath(path string) (string, error) {
 	}
 	return filepath.Abs(expanded)
 }
+
+// FingerprintPath hashes the metadata (name, mode, size and modification time)
+// of a client file, or of the files of a client directory selected by opt, to
+// detect changes without reading their contents
+func FingerprintPath(ctx context.Context, path string, opt *fsutil.WalkOpt) (string, error) {
+	h := sha256.New()
+
+	fi, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		fmt.Fprint(h, "missing")
+	case err != nil:
+		return "", err
+	case !fi.IsDir():
+		fmt.Fprintf(h, "%o %d %d\n", fi.Mode(), fi.Size(), fi.ModTime().UnixNano())
+	default:
+		err := fsutil.Walk(ctx, path, opt, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %o %d %d\n", filepath.ToSlash(p), fi.Mode(), fi.Size(), fi.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -31,6 +31,7 @@ func New() *Context {
 		},
 		Secrets: &secretContext{
 			store: make(map[string]*Secret),
+			audit: make(map[string]*SecretUsage),
 		},
 		Services: &serviceContext{
 			store: make(map[string]*Service),
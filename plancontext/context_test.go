@@ -1,8 +1,13 @@
 package plancontext
 
 import (
+	"context"
+	"io"
+	"net"
 	"testing"
 
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,3 +19,92 @@ func TestContext(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "test", get.PlainText())
 }
+
+// stateRef is a reference to the result of a state
+type stateRef struct {
+	bkgw.Reference
+	st llb.State
+}
+
+func (r stateRef) ToState() (llb.State, error) {
+	return r.st, nil
+}
+
+func TestFSID(t *testing.T) {
+	ctx := New()
+
+	local := func(session string) llb.State {
+		return llb.Image("alpine").File(llb.Copy(
+			llb.Local("/src", llb.SessionID(session)),
+			"/",
+			"/src",
+		))
+	}
+
+	// Identical filesystems share the same ID, even across sessions, but keep
+	// their own result
+	fs1 := ctx.FS.New(stateRef{st: local("session1")})
+	fs2 := ctx.FS.New(stateRef{st: local("session2")})
+	require.Equal(t, fs1.ID(), fs2.ID())
+	require.NotEqual(t, fs1.Result(), fs2.Result())
+
+	// The latest result is returned
+	get, err := ctx.FS.FromValue(fs1.MarshalCUE())
+	require.NoError(t, err)
+	require.Equal(t, fs2, get)
+
+	other := ctx.FS.New(stateRef{st: llb.Image("busybox")})
+	require.NotEqual(t, fs1.ID(), other.ID())
+
+	// Ops run without cache don't share the ID of cached ones
+	cached := ctx.FS.New(stateRef{st: llb.Image("alpine").Run(llb.Shlex("date")).Root()})
+	uncached := ctx.FS.New(stateRef{st: llb.Image("alpine").Run(llb.Shlex("date"), llb.IgnoreCache).Root()})
+	require.NotEqual(t, cached.ID(), uncached.ID())
+}
+
+func TestServiceListen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pctx := New()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	service := pctx.Services.Listen(ctx, l, "", "", l.Addr().String())
+	require.True(t, service.Listening())
+	require.Equal(t, service, pctx.Services.Get(service.ID()))
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := service.Accept(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = client.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+
+	// The listener is closed with the context
+	cancel()
+	_, err = service.Accept(context.Background())
+	require.Error(t, err)
+}
+
+func TestSecretRedact(t *testing.T) {
+	ctx := New()
+
+	require.Equal(t, "nothing to hide", ctx.Secrets.Redact("nothing to hide"))
+
+	ctx.Secrets.New("pass word")
+	ctx.Secrets.New("pass word+more")
+
+	require.Equal(t, "token=***", ctx.Secrets.Redact("token=pass word"))
+	require.Equal(t, "token=***", ctx.Secrets.Redact("token=pass word+more"))
+	require.Equal(t, "basic ***", ctx.Secrets.Redact("basic cGFzcyB3b3Jk"))
+	require.Equal(t, "?q=***", ctx.Secrets.Redact("?q=pass+word"))
+	require.Equal(t, "/***/", ctx.Secrets.Redact("/pass%20word/"))
+}
@@ -1,6 +1,8 @@
 package plancontext
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -8,6 +10,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/moby/buildkit/client/llb"
 	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/opencontainers/go-digest"
 	"go.dagger.io/dagger/compiler"
 	"go.dagger.io/dagger/pkg"
 )
@@ -29,6 +33,14 @@ type FS struct {
 	result bkgw.Reference
 }
 
+// ID identifies the filesystem. It's empty for #Scratch.
+func (fs *FS) ID() string {
+	if fs.result == nil {
+		return ""
+	}
+	return fs.id
+}
+
 func (fs *FS) Result() bkgw.Reference {
 	return fs.result
 }
@@ -60,12 +72,15 @@ type fsContext struct {
 }
 
 func (c *fsContext) New(result bkgw.Reference) *FS {
+	id := fsID(result)
+
 	c.l.Lock()
 	defer c.l.Unlock()
 
+	// Identical filesystems share the same ID, but each keeps its own result:
+	// its session may have changed since. Lookups get the latest result.
 	fs := &FS{
-		// FIXME: get a hash from result instead
-		id:     uuid.New().String(),
+		id:     id,
 		result: result,
 	}
 
@@ -73,6 +88,67 @@ func (c *fsContext) New(result bkgw.Reference) *FS {
 	return fs
 }
 
+// fsID derives the ID of a filesystem from the LLB definition of its result,
+// so that filesystems built the same way have the same ID, across tasks and
+// runs. Note that client directories are identified by their path and filters,
+// not their contents.
+func fsID(result bkgw.Reference) string {
+	if result != nil {
+		if st, err := result.ToState(); err == nil {
+			if def, err := st.Marshal(context.TODO()); err == nil {
+				if dgst, err := stableDigest(def); err == nil {
+					return dgst.String()
+				}
+			}
+		}
+	}
+
+	// The definition isn't available
+	return uuid.New().String()
+}
+
+// stableDigest returns the digest of the output of a definition, ignoring the
+// session of client directories, which changes every run. Ops run without
+// cache are told apart from cached ones, as their results may differ.
+func stableDigest(def *llb.Definition) (digest.Digest, error) {
+	if len(def.Def) == 0 {
+		return "", errors.New("empty definition")
+	}
+
+	// Ops are sorted: inputs come first
+	digests := make(map[digest.Digest]digest.Digest, len(def.Def))
+	var last digest.Digest
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := (&op).Unmarshal(dt); err != nil {
+			return "", err
+		}
+
+		if src := op.GetSource(); src != nil {
+			delete(src.Attrs, pb.AttrLocalSessionID)
+		}
+		for _, input := range op.Inputs {
+			if dgst, ok := digests[input.Digest]; ok {
+				input.Digest = dgst
+			}
+		}
+
+		stable, err := (&op).Marshal()
+		if err != nil {
+			return "", err
+		}
+		dgst := digest.FromBytes(dt)
+		if def.Metadata[dgst].IgnoreCache {
+			stable = append(stable, []byte("ignore-cache")...)
+		}
+		last = digest.FromBytes(stable)
+		digests[dgst] = last
+	}
+
+	// The last op points to the output of the definition
+	return last, nil
+}
+
 func (c *fsContext) FromValue(v *compiler.Value) (*FS, error) {
 	c.l.RLock()
 	defer c.l.RUnlock()
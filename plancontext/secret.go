@@ -1,7 +1,11 @@
 package plancontext
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
 
 	"cuelang.org/go/cue"
@@ -42,9 +46,50 @@ func (s *Secret) MarshalCUE() *compiler.Value {
 	return v
 }
 
+// encodings returns the forms under which the secret may leak into logs
+func (s *Secret) encodings() []string {
+	if s.plainText == "" {
+		return nil
+	}
+
+	b := []byte(s.plainText)
+	return []string{
+		s.plainText,
+		base64.StdEncoding.EncodeToString(b),
+		base64.RawStdEncoding.EncodeToString(b),
+		base64.URLEncoding.EncodeToString(b),
+		base64.RawURLEncoding.EncodeToString(b),
+		url.QueryEscape(s.plainText),
+		url.PathEscape(s.plainText),
+	}
+}
+
+// SecretRef locates a secret in the plan
+type SecretRef struct {
+	// Path of the field holding the secret
+	Path string `json:"path"`
+	// Type of the task producing or consuming the secret (e.g. `ClientEnv`)
+	Task string `json:"task"`
+	// Environment variable the secret was injected as, if any
+	Env string `json:"env,omitempty"`
+	// Destination of the mount the secret was injected as, if any
+	Mount string `json:"mount,omitempty"`
+}
+
+// SecretUsage reports where a secret was sourced and where it was injected,
+// without revealing its value
+type SecretUsage struct {
+	Sources []SecretRef `json:"sources"`
+	Usages  []SecretRef `json:"usages"`
+}
+
 type secretContext struct {
 	l     sync.RWMutex
 	store map[string]*Secret
+	audit map[string]*SecretUsage
+
+	// Lazily built from the store, reset when a secret is added
+	redactor *strings.Replacer
 }
 
 func (c *secretContext) New(plaintext string) *Secret {
@@ -57,9 +102,88 @@ func (c *secretContext) New(plaintext string) *Secret {
 	defer c.l.Unlock()
 
 	c.store[secret.id] = secret
+	c.redactor = nil
 	return secret
 }
 
+// AddSource records a field the secret was sourced from (e.g. `client.env.TOKEN`)
+func (c *secretContext) AddSource(s *Secret, ref SecretRef) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	u := c.usage(s.id)
+	u.Sources = appendRef(u.Sources, ref)
+}
+
+// AddUsage records a field of a task the secret was injected into
+func (c *secretContext) AddUsage(s *Secret, ref SecretRef) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	u := c.usage(s.id)
+	u.Usages = appendRef(u.Usages, ref)
+}
+
+func (c *secretContext) usage(id string) *SecretUsage {
+	u, ok := c.audit[id]
+	if !ok {
+		u = &SecretUsage{}
+		c.audit[id] = u
+	}
+	return u
+}
+
+// Report returns the sources and usages of all the audited secrets
+func (c *secretContext) Report() []SecretUsage {
+	c.l.RLock()
+	defer c.l.RUnlock()
+
+	report := make([]SecretUsage, 0, len(c.audit))
+	for _, u := range c.audit {
+		report = append(report, SecretUsage{
+			Sources: sortRefs(u.Sources),
+			Usages:  sortRefs(u.Usages),
+		})
+	}
+	SortSecretUsages(report)
+
+	return report
+}
+
+// SortSecretUsages sorts a report by the path of the first source of each secret
+func SortSecretUsages(report []SecretUsage) {
+	key := func(u SecretUsage) string {
+		if len(u.Sources) > 0 {
+			return u.Sources[0].Path
+		}
+		if len(u.Usages) > 0 {
+			return u.Usages[0].Path
+		}
+		return ""
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		return key(report[i]) < key(report[j])
+	})
+}
+
+func appendRef(refs []SecretRef, ref SecretRef) []SecretRef {
+	for _, r := range refs {
+		if r == ref {
+			return refs
+		}
+	}
+	return append(refs, ref)
+}
+
+func sortRefs(refs []SecretRef) []SecretRef {
+	sorted := append([]SecretRef{}, refs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	return sorted
+}
+
 func (c *secretContext) FromValue(v *compiler.Value) (*Secret, error) {
 	c.l.RLock()
 	defer c.l.RUnlock()
@@ -99,3 +223,41 @@ func (c *secretContext) List() []*Secret {
 
 	return secrets
 }
+
+// Redact replaces all the known secrets in s, including their base64
+// and URL encoded forms.
+func (c *secretContext) Redact(s string) string {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if c.redactor == nil {
+		seen := map[string]struct{}{}
+		forms := []string{}
+		for _, secret := range c.store {
+			for _, form := range secret.encodings() {
+				if _, ok := seen[form]; ok {
+					continue
+				}
+				seen[form] = struct{}{}
+				forms = append(forms, form)
+			}
+		}
+
+		// Longer forms first, so that a secret containing another one is
+		// entirely redacted
+		sort.Slice(forms, func(i, j int) bool {
+			if len(forms[i]) != len(forms[j]) {
+				return len(forms[i]) > len(forms[j])
+			}
+			return forms[i] < forms[j]
+		})
+
+		oldnew := make([]string, 0, 2*len(forms))
+		for _, form := range forms {
+			oldnew = append(oldnew, form, "***")
+		}
+		c.redactor = strings.NewReplacer(oldnew...)
+	}
+
+	return c.redactor.Replace(s)
+}
@@ -1,7 +1,9 @@
 package plancontext
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"sync"
 
 	"cuelang.org/go/cue"
@@ -26,6 +28,10 @@ type Service struct {
 
 	unix  string
 	npipe string
+	tcp   string
+
+	// Connections accepted on the client, for services in listen mode
+	conns chan net.Conn
 }
 
 func (s *Service) ID() string {
@@ -40,6 +46,30 @@ func (s *Service) NPipe() string {
 	return s.npipe
 }
 
+func (s *Service) TCP() string {
+	return s.tcp
+}
+
+// Listening returns true if the service accepts connections on the client
+// instead of connecting to an existing endpoint.
+func (s *Service) Listening() bool {
+	return s.conns != nil
+}
+
+// Accept waits for the next connection made by the client to a listening
+// service.
+func (s *Service) Accept(ctx context.Context) (net.Conn, error) {
+	select {
+	case conn, ok := <-s.conns:
+		if !ok {
+			return nil, net.ErrClosed
+		}
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (s *Service) MarshalCUE() *compiler.Value {
 	v := compiler.NewValue()
 	if err := v.FillPath(serviceIDPath, s.id); err != nil {
@@ -53,20 +83,60 @@ type serviceContext struct {
 	store map[string]*Service
 }
 
-func (c *serviceContext) New(unix, npipe string) *Service {
+func (c *serviceContext) New(unix, npipe, tcp string) *Service {
 	c.l.Lock()
 	defer c.l.Unlock()
 
 	s := &Service{
-		id:    hashID(unix, npipe),
+		id:    hashID(unix, npipe, tcp),
 		unix:  unix,
 		npipe: npipe,
+		tcp:   tcp,
 	}
 
 	c.store[s.id] = s
 	return s
 }
 
+// Listen registers a service accepting connections from `l`.
+// Connections are handed over to `Accept` callers until `ctx` is done.
+func (c *serviceContext) Listen(ctx context.Context, l net.Listener, unix, npipe, tcp string) *Service {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	s := &Service{
+		id:    hashID("listen", unix, npipe, tcp),
+		unix:  unix,
+		npipe: npipe,
+		tcp:   tcp,
+		conns: make(chan net.Conn),
+	}
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	go func() {
+		defer close(s.conns)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			select {
+			case s.conns <- conn:
+			case <-ctx.Done():
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	c.store[s.id] = s
+	return s
+}
+
 func (c *serviceContext) FromValue(v *compiler.Value) (*Service, error) {
 	c.l.RLock()
 	defer c.l.RUnlock()
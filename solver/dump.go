@@ -0,0 +1,151 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/containerd/containerd/platforms"
+	bkpb "github.com/moby/buildkit/solver/pb"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Dump is a definition solved by a task, written to disk to replay it later
+// (see `dagger do --dump-llb`)
+type Dump struct {
+	// Path of the task which solved the definition, if any
+	Task     string `json:"task,omitempty"`
+	Platform string `json:"platform"`
+
+	// Digest of the definition
+	Digest digest.Digest `json:"digest"`
+
+	// Client directories read by the definition, by name
+	LocalDirs map[string]string `json:"localDirs,omitempty"`
+
+	// Protobuf definition, to replay it
+	Definition []byte `json:"definition"`
+
+	// Operations of the definition, for humans
+	Ops json.RawMessage `json:"ops"`
+}
+
+// ReadDump reads a definition written by `dagger do --dump-llb`
+func ReadDump(path string) (*Dump, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &Dump{}
+	if err := json.Unmarshal(data, dump); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(dump.Definition) == 0 {
+		return nil, fmt.Errorf("%s: missing definition", path)
+	}
+	return dump, nil
+}
+
+// PB returns the protobuf definition
+func (d *Dump) PB() (*bkpb.Definition, error) {
+	def := &bkpb.Definition{}
+	if err := def.Unmarshal(d.Definition); err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+	return def, nil
+}
+
+type taskKey struct{}
+
+// WithTask returns a context to solve the definitions of a task. The path of
+// the task is recorded in dumps.
+func WithTask(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, taskKey{}, path)
+}
+
+func taskFromContext(ctx context.Context) string {
+	path, _ := ctx.Value(taskKey{}).(string)
+	return path
+}
+
+// dump writes a definition to the dump directory, if set
+func (s Solver) dump(ctx context.Context, def *bkpb.Definition, platform specs.Platform) error {
+	if s.opts.DumpDir == "" {
+		return nil
+	}
+
+	if err := s.writeDump(ctx, def, platform); err != nil {
+		return fmt.Errorf("failed to dump definition: %w", err)
+	}
+	return nil
+}
+
+func (s Solver) writeDump(ctx context.Context, def *bkpb.Definition, platform specs.Platform) error {
+	data, err := def.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ops, err := dumpLLB(def)
+	if err != nil {
+		return err
+	}
+
+	dump := &Dump{
+		Task:       taskFromContext(ctx),
+		Platform:   platforms.Format(platform),
+		Digest:     digest.FromBytes(data),
+		Definition: data,
+		Ops:        ops,
+	}
+
+	if s.opts.Context != nil {
+		dirs, err := s.opts.Context.LocalDirs.Paths()
+		if err != nil {
+			return err
+		}
+		for _, name := range localNames(def) {
+			if dump.LocalDirs == nil {
+				dump.LocalDirs = make(map[string]string)
+			}
+			dump.LocalDirs[name] = dirs[name]
+		}
+	}
+
+	out, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.opts.DumpDir, 0755); err != nil {
+		return err
+	}
+
+	// Files are numbered in the order of the solves
+	name := fmt.Sprintf("%04d", atomic.AddInt64(s.dumpSeq, 1))
+	if dump.Task != "" {
+		name += "-" + strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(dump.Task)
+	}
+	return os.WriteFile(filepath.Join(s.opts.DumpDir, name+".json"), out, 0600)
+}
+
+// localNames returns the names of the client directories read by a definition
+func localNames(def *bkpb.Definition) []string {
+	names := []string{}
+	for _, dt := range def.Def {
+		var op bkpb.Op
+		if err := (&op).Unmarshal(dt); err != nil {
+			continue
+		}
+		if src := op.GetSource(); src != nil && strings.HasPrefix(src.Identifier, "local://") {
+			names = append(names, strings.TrimPrefix(src.Identifier, "local://"))
+		}
+	}
+	return names
+}
@@ -0,0 +1,49 @@
+package solver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+	"go.dagger.io/dagger/plancontext"
+)
+
+func TestDump(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	pctx := plancontext.New()
+	pctx.LocalDirs.Add("/src")
+	pctx.LocalDirs.Add("/unused")
+
+	s := New(Opts{
+		Context: pctx,
+		DumpDir: dir,
+	})
+
+	st := llb.Image("alpine").File(llb.Copy(llb.Local("/src"), "/", "/src"))
+	def, err := s.Marshal(ctx, st, llb.LinuxAmd64)
+	require.NoError(t, err)
+
+	require.NoError(t, s.dump(WithTask(ctx, "actions.build"), def, pctx.Platform.Get()))
+	require.NoError(t, s.dump(ctx, def, pctx.Platform.Get()))
+
+	dump, err := ReadDump(filepath.Join(dir, "0001-actions.build.json"))
+	require.NoError(t, err)
+	require.Equal(t, "actions.build", dump.Task)
+	require.Equal(t, "linux/amd64", dump.Platform)
+	src, err := filepath.Abs("/src")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"/src": src}, dump.LocalDirs)
+
+	replayed, err := dump.PB()
+	require.NoError(t, err)
+	require.Equal(t, def.Def, replayed.Def)
+
+	// Solves outside of a task are dumped too
+	dump, err = ReadDump(filepath.Join(dir, "0002.json"))
+	require.NoError(t, err)
+	require.Empty(t, dump.Task)
+}
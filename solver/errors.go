@@ -0,0 +1,93 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	gwpb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/util/grpcerrors"
+	"github.com/opencontainers/go-digest"
+	"google.golang.org/grpc/codes"
+)
+
+// Error is a buildkit error, with the noise removed from its message. The
+// original error is kept to inspect the failure (see ExecFailure).
+type Error struct {
+	msg string
+	err error
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// A helper to remove noise from buildkit error messages.
+// FIXME: Obviously a cleaner solution would be nice.
+func CleanError(err error) error {
+	noise := []string{
+		"executor failed running ",
+		"buildkit-runc did not terminate successfully",
+		"rpc error: code = Unknown desc = ",
+		"failed to solve: ",
+	}
+
+	msg := err.Error()
+
+	for _, s := range noise {
+		msg = strings.ReplaceAll(msg, s, "")
+	}
+
+	return &Error{msg: msg, err: err}
+}
+
+// ExecError is a command which failed in buildkit
+type ExecError struct {
+	Args []string
+
+	// Exit code of the command, -1 if unknown
+	ExitCode int
+
+	// Vertex of the command, to look up its output
+	Vertex digest.Digest
+}
+
+// IsCanceled returns whether err is the cancellation of a solve, locally or by
+// buildkit
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || grpcerrors.Code(err) == codes.Canceled
+}
+
+// ExecFailure returns the command which caused err, if any
+func ExecFailure(err error) *ExecError {
+	var solveErr *errdefs.SolveError
+	if !errors.As(err, &solveErr) {
+		return nil
+	}
+	exec := solveErr.Op.GetExec()
+	if exec == nil || exec.Meta == nil {
+		return nil
+	}
+
+	e := &ExecError{
+		Args:     exec.Meta.Args,
+		ExitCode: -1,
+	}
+
+	var exitErr *gwpb.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode != gwpb.UnknownExitStatus {
+		e.ExitCode = int(exitErr.ExitCode)
+	}
+
+	var vertexErr *errdefs.VertexError
+	if errors.As(err, &vertexErr) {
+		e.Vertex = digest.Digest(vertexErr.Digest)
+	}
+
+	return e
+}
@@ -0,0 +1,347 @@
+package solver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	fstypes "github.com/tonistiigi/fsutil/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// Size of the chunks read from the gateway, below the grpc message limit
+	readChunkSize = 4 << 20
+
+	// Number of files read from the gateway in parallel
+	readConcurrency = 8
+
+	// Mode bits kept on export, besides the permissions
+	specialModeBits = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+)
+
+// exportLocal writes the files of ref to dest, on the client, through the
+// gateway session. Like the buildkit local exporter, files which are already
+// in dest and not in ref are left untouched, and written files are owned by
+// the client user. Hard links are only preserved within a directory: the
+// gateway doesn't report links across directories, which are written as
+// copies.
+func (s Solver) exportLocal(ctx context.Context, ref bkgw.Reference, dest string) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, readConcurrency)
+
+	// Directory times are set last, since writing their contents changes them
+	var dirs []*fstypes.Stat
+	// Hard links are created once the files they point to are written
+	var links []*fstypes.Stat
+
+	err := walkRef(ctx, ref, "/", func(p string, st *fstypes.Stat) error {
+		target := filepath.Join(dest, filepath.FromSlash(p))
+		mode := os.FileMode(st.Mode)
+
+		switch {
+		case mode.IsDir():
+			if err := mkdirReplace(target); err != nil {
+				return err
+			}
+			dirs = append(dirs, &fstypes.Stat{Path: target, Mode: st.Mode, ModTime: st.ModTime})
+		case mode&os.ModeSymlink != 0:
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			return os.Symlink(st.Linkname, target)
+		case mode.IsRegular() && st.Linkname != "":
+			links = append(links, &fstypes.Stat{Path: target, Linkname: filepath.Join(dest, filepath.FromSlash(st.Linkname))})
+		case mode.IsRegular():
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			eg.Go(func() error {
+				defer func() { <-sem }()
+				return writeRefFile(ctx, ref, p, st, target)
+			})
+		default:
+			// Devices, fifos and sockets can't be exported to the client
+		}
+		return nil
+	})
+	if werr := eg.Wait(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, l := range links {
+		if err := os.RemoveAll(l.Path); err != nil {
+			return err
+		}
+		if err := os.Link(l.Linkname, l.Path); err != nil {
+			return err
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.Path, exportMode(d)); err != nil {
+			return err
+		}
+		mtime := time.Unix(0, d.ModTime)
+		if err := os.Chtimes(d.Path, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mkdirReplace makes target a writable directory. Anything else in its place,
+// including a symlink to a directory, is removed first so that nothing is
+// written outside of the export destination.
+func mkdirReplace(target string) error {
+	fi, err := os.Lstat(target)
+	switch {
+	case err == nil && fi.IsDir():
+		// Its final mode is applied once its contents are written
+		return os.Chmod(target, fi.Mode().Perm()|0700)
+	case err == nil:
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	return os.Mkdir(target, 0755)
+}
+
+// writeRefFile copies a regular file of ref to target
+func writeRefFile(ctx context.Context, ref bkgw.Reference, p string, st *fstypes.Stat, target string) error {
+	// Replace rather than truncate, the target may be a hard link
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, os.FileMode(st.Mode).Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := readRefFile(ctx, ref, p, st.Size_, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Apply the mode again, it's masked by the umask on creation
+	if err := os.Chmod(target, exportMode(st)); err != nil {
+		return err
+	}
+	mtime := time.Unix(0, st.ModTime)
+	return os.Chtimes(target, mtime, mtime)
+}
+
+// exportTar writes the files of ref to w as a tarball, through the gateway
+// session. The tarball is written in order, so small files are read ahead in
+// parallel rather than with a round trip each in turn.
+func (s Solver) exportTar(ctx context.Context, ref bkgw.Reference, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	// Bounds the number of files read ahead
+	entries := make(chan *tarEntry, readConcurrency)
+
+	eg.Go(func() error {
+		defer close(entries)
+		return walkRef(ctx, ref, "/", func(p string, st *fstypes.Stat) error {
+			hdr := tarHeader(p, st)
+			if hdr == nil {
+				return nil
+			}
+
+			e := &tarEntry{hdr: hdr}
+			if hdr.Typeflag == tar.TypeReg && hdr.Size <= readChunkSize {
+				e.done = make(chan struct{})
+				go func() {
+					defer close(e.done)
+					buf := &bytes.Buffer{}
+					e.err = readRefFile(ctx, ref, p, hdr.Size, buf)
+					e.data = buf.Bytes()
+				}()
+			}
+
+			select {
+			case entries <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	})
+
+	eg.Go(func() error {
+		for e := range entries {
+			if err := tw.WriteHeader(e.hdr); err != nil {
+				return err
+			}
+			if e.hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if e.done == nil {
+				if err := readRefFile(ctx, ref, e.hdr.Name, e.hdr.Size, tw); err != nil {
+					return err
+				}
+				continue
+			}
+
+			select {
+			case <-e.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if e.err != nil {
+				return e.err
+			}
+			if _, err := tw.Write(e.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// tarEntry is a file of a tar export, with the contents of a small file once
+// done is closed
+type tarEntry struct {
+	hdr  *tar.Header
+	data []byte
+	err  error
+	done chan struct{}
+}
+
+// tarHeader returns the tar header of a file of ref, or nil if the file can't
+// be exported
+func tarHeader(p string, st *fstypes.Stat) *tar.Header {
+	mode := os.FileMode(st.Mode)
+	hdr := &tar.Header{
+		Name:    p,
+		Mode:    tarMode(exportMode(st)),
+		Uid:     int(st.Uid),
+		Gid:     int(st.Gid),
+		ModTime: time.Unix(0, st.ModTime),
+	}
+
+	switch {
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case mode&os.ModeSymlink != 0:
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = st.Linkname
+	case mode.IsRegular() && st.Linkname != "":
+		hdr.Typeflag = tar.TypeLink
+		hdr.Linkname = st.Linkname
+	case mode.IsRegular():
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = st.Size_
+	default:
+		return nil
+	}
+	return hdr
+}
+
+// exportMode returns the permissions of st, with its setuid, setgid and sticky
+// bits
+func exportMode(st *fstypes.Stat) os.FileMode {
+	mode := os.FileMode(st.Mode)
+	return mode.Perm() | mode&specialModeBits
+}
+
+// tarMode converts mode to the unix mode bits of a tar header
+func tarMode(mode os.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// walkRef calls fn for each file of ref under dir, parents first. Paths are
+// relative to the root of ref. A nil ref is an empty filesystem. Regular files
+// with a Linkname are hard links to a file of the same directory, already
+// visited.
+func walkRef(ctx context.Context, ref bkgw.Reference, dir string, fn func(string, *fstypes.Stat) error) error {
+	if ref == nil {
+		return nil
+	}
+
+	entries, err := ref.ReadDir(ctx, bkgw.ReadDirRequest{Path: dir})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	for _, st := range entries {
+		p := path.Join(dir, path.Base(st.Path))
+		rel := p[1:]
+		if os.FileMode(st.Mode).IsRegular() && st.Linkname != "" {
+			// Links are relative to the directory read
+			st.Linkname = path.Join(dir, st.Linkname)[1:]
+		}
+		if err := fn(rel, st); err != nil {
+			return err
+		}
+		if os.FileMode(st.Mode).IsDir() {
+			if err := walkRef(ctx, ref, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readRefFile copies the contents of a file of ref to w, in chunks
+func readRefFile(ctx context.Context, ref bkgw.Reference, p string, size int64, w io.Writer) error {
+	for offset := int64(0); offset < size; {
+		data, err := ref.ReadFile(ctx, bkgw.ReadRequest{
+			Filename: p,
+			Range: &bkgw.FileRange{
+				Offset: int(offset),
+				Length: readChunkSize,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("failed to read %s: unexpected end of file", p)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		offset += int64(len(data))
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package solver
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// dirRef is a gateway reference backed by a local directory
+type dirRef struct {
+	root string
+}
+
+func (r dirRef) ToState() (llb.State, error) {
+	return llb.State{}, errors.New("not implemented")
+}
+
+func (r dirRef) ReadFile(ctx context.Context, req bkgw.ReadRequest) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(r.root, req.Filename))
+	if err != nil || req.Range == nil {
+		return data, err
+	}
+	start := req.Range.Offset
+	if start > len(data) {
+		start = len(data)
+	}
+	end := start + req.Range.Length
+	if end > len(data) {
+		end = len(data)
+	}
+	return data[start:end], nil
+}
+
+func (r dirRef) StatFile(ctx context.Context, req bkgw.StatRequest) (*fstypes.Stat, error) {
+	return r.stat(filepath.Join(r.root, req.Path))
+}
+
+func (r dirRef) ReadDir(ctx context.Context, req bkgw.ReadDirRequest) ([]*fstypes.Stat, error) {
+	entries, err := os.ReadDir(filepath.Join(r.root, req.Path))
+	if err != nil {
+		return nil, err
+	}
+	// Like the gateway, hard links are reported within a directory
+	seen := map[string]os.FileInfo{}
+	stats := []*fstypes.Stat{}
+	for _, e := range entries {
+		st, err := r.stat(filepath.Join(r.root, req.Path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if e.Type().IsRegular() {
+			fi, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			for name, other := range seen {
+				if os.SameFile(fi, other) {
+					st.Linkname = name
+					st.Size_ = 0
+				}
+			}
+			if st.Linkname == "" {
+				seen[e.Name()] = fi
+			}
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+func (r dirRef) stat(p string) (*fstypes.Stat, error) {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	st := &fstypes.Stat{
+		Path:    fi.Name(),
+		Mode:    uint32(fi.Mode()),
+		Size_:   fi.Size(),
+		ModTime: fi.ModTime().UnixNano(),
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if st.Linkname, err = os.Readlink(p); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func testRef(t *testing.T) dirRef {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub", "empty"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "foo"), []byte("foo"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "script.sh"), []byte("#!/bin/sh"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "empty.txt"), nil, 0644))
+	require.NoError(t, os.Symlink("sub/script.sh", filepath.Join(root, "link")))
+	require.NoError(t, os.Link(filepath.Join(root, "sub", "script.sh"), filepath.Join(root, "sub", "tool")))
+	require.NoError(t, os.Chmod(filepath.Join(root, "sub", "script.sh"), 0755|os.ModeSetuid))
+	require.NoError(t, os.Chmod(filepath.Join(root, "sub", "empty"), 0700|os.ModeSticky))
+	return dirRef{root: root}
+}
+
+func TestExportLocal(t *testing.T) {
+	ref := testRef(t)
+	dest := t.TempDir()
+
+	// Files missing from the reference are kept
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "keep"), []byte("keep"), 0600))
+	// Existing files are replaced
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "foo"), []byte("old contents"), 0644))
+
+	require.NoError(t, Solver{}.exportLocal(context.Background(), ref, dest))
+
+	data, err := os.ReadFile(filepath.Join(dest, "foo"))
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dest, "keep"))
+	require.NoError(t, err)
+	require.Equal(t, "keep", string(data))
+
+	fi, err := os.Stat(filepath.Join(dest, "sub", "script.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+	require.Equal(t, os.ModeSetuid, fi.Mode()&specialModeBits)
+
+	fi, err = os.Stat(filepath.Join(dest, "sub", "empty.txt"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), fi.Size())
+
+	fi, err = os.Stat(filepath.Join(dest, "sub", "empty"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+	require.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+	require.Equal(t, os.ModeSticky, fi.Mode()&specialModeBits)
+
+	link, err := os.Readlink(filepath.Join(dest, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "sub/script.sh", link)
+
+	// Hard links are preserved
+	script, err := os.Stat(filepath.Join(dest, "sub", "script.sh"))
+	require.NoError(t, err)
+	tool, err := os.Stat(filepath.Join(dest, "sub", "tool"))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(script, tool))
+}
+
+func TestExportLocalReplace(t *testing.T) {
+	ref := testRef(t)
+
+	// A symlink to a directory isn't followed
+	outside := t.TempDir()
+	dest := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dest, "sub")))
+
+	require.NoError(t, Solver{}.exportLocal(context.Background(), ref, dest))
+
+	entries, err := os.ReadDir(outside)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	fi, err := os.Lstat(filepath.Join(dest, "sub"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+	data, err := os.ReadFile(filepath.Join(dest, "sub", "script.sh"))
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh", string(data))
+
+	// A file is replaced by a directory
+	dest = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "sub"), []byte("file"), 0600))
+
+	require.NoError(t, Solver{}.exportLocal(context.Background(), ref, dest))
+
+	data, err = os.ReadFile(filepath.Join(dest, "sub", "script.sh"))
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh", string(data))
+
+	// Read-only directories are written again
+	require.NoError(t, os.Chmod(filepath.Join(dest, "sub"), 0500))
+	require.NoError(t, Solver{}.exportLocal(context.Background(), ref, dest))
+}
+
+func TestExportTar(t *testing.T) {
+	ref := testRef(t)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Solver{}.exportTar(context.Background(), ref, buf))
+
+	files := map[string]string{}
+	modes := map[string]int64{}
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		modes[hdr.Name] = hdr.Mode
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			files[hdr.Name] = "-> " + hdr.Linkname
+		case tar.TypeLink:
+			files[hdr.Name] = "=> " + hdr.Linkname
+		default:
+			files[hdr.Name] = string(data)
+		}
+	}
+
+	require.Equal(t, map[string]string{
+		"foo":           "foo",
+		"link":          "-> sub/script.sh",
+		"sub/":          "",
+		"sub/empty/":    "",
+		"sub/empty.txt": "",
+		"sub/script.sh": "#!/bin/sh",
+		"sub/tool":      "=> sub/script.sh",
+	}, files)
+
+	// Special bits are converted like the permissions
+	require.Equal(t, int64(04755), modes["sub/script.sh"])
+	require.Equal(t, int64(01700), modes["sub/empty/"])
+}
+
+func TestExportEmpty(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "out")
+	require.NoError(t, Solver{}.exportLocal(context.Background(), nil, dest))
+
+	entries, err := os.ReadDir(dest)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+// slowRef counts the calls to the gateway, each taking the latency of a round
+// trip
+type slowRef struct {
+	dirRef
+	latency time.Duration
+	calls   *int64
+}
+
+func (r slowRef) call() {
+	atomic.AddInt64(r.calls, 1)
+	time.Sleep(r.latency)
+}
+
+func (r slowRef) ReadFile(ctx context.Context, req bkgw.ReadRequest) ([]byte, error) {
+	r.call()
+	return r.dirRef.ReadFile(ctx, req)
+}
+
+func (r slowRef) ReadDir(ctx context.Context, req bkgw.ReadDirRequest) ([]*fstypes.Stat, error) {
+	r.call()
+	return r.dirRef.ReadDir(ctx, req)
+}
+
+// BenchmarkExport measures exports through the gateway session, with a local
+// round trip latency. Tar exports are written to io.Discard, to measure the
+// reads from the gateway without the writes to the disk.
+func BenchmarkExport(b *testing.B) {
+	for _, bench := range []struct {
+		name  string
+		dirs  int
+		files int
+		size  int
+	}{
+		{name: "small files", dirs: 10, files: 100, size: 1 << 10},
+		{name: "large file", dirs: 1, files: 1, size: 32 << 20},
+	} {
+		root := b.TempDir()
+		data := bytes.Repeat([]byte("x"), bench.size)
+		for d := 0; d < bench.dirs; d++ {
+			dir := filepath.Join(root, strconv.Itoa(d))
+			require.NoError(b, os.Mkdir(dir, 0755))
+			for f := 0; f < bench.files; f++ {
+				require.NoError(b, os.WriteFile(filepath.Join(dir, strconv.Itoa(f)), data, 0644))
+			}
+		}
+
+		for _, export := range []struct {
+			name string
+			fn   func(context.Context, bkgw.Reference) error
+		}{
+			{name: "local", fn: func(ctx context.Context, ref bkgw.Reference) error {
+				return Solver{}.exportLocal(ctx, ref, filepath.Join(b.TempDir(), "out"))
+			}},
+			{name: "tar", fn: func(ctx context.Context, ref bkgw.Reference) error {
+				return Solver{}.exportTar(ctx, ref, io.Discard)
+			}},
+		} {
+			b.Run(export.name+"/"+bench.name, func(b *testing.B) {
+				ref := slowRef{
+					dirRef:  dirRef{root: root},
+					latency: 100 * time.Microsecond,
+					calls:   new(int64),
+				}
+
+				b.SetBytes(int64(bench.dirs * bench.files * bench.size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					require.NoError(b, export.fn(context.Background(), ref))
+				}
+				b.ReportMetric(float64(*ref.calls)/float64(b.N), "calls/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package solver
+
+import (
+	"bytes"
+	"sync"
+
+	bk "github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+)
+
+// VertexLogs keeps the last lines of output of each vertex, to report them
+// when a command fails
+type VertexLogs struct {
+	max   int
+	lines map[digest.Digest][]string
+
+	// Last line of each vertex, until it's terminated
+	partial map[digest.Digest][]byte
+
+	l sync.Mutex
+}
+
+func NewVertexLogs(max int) *VertexLogs {
+	return &VertexLogs{
+		max:     max,
+		lines:   make(map[digest.Digest][]string),
+		partial: make(map[digest.Digest][]byte),
+	}
+}
+
+// Add records the output of a status update
+func (l *VertexLogs) Add(status *bk.SolveStatus) {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	for _, log := range status.Logs {
+		data := append(l.partial[log.Vertex], log.Data...)
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			l.addLine(log.Vertex, string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+			data = data[i+1:]
+		}
+		l.partial[log.Vertex] = data
+	}
+}
+
+func (l *VertexLogs) addLine(v digest.Digest, line string) {
+	lines := append(l.lines[v], line)
+	if len(lines) > l.max {
+		lines = lines[len(lines)-l.max:]
+	}
+	l.lines[v] = lines
+}
+
+// Tail returns the last lines of output of a vertex
+func (l *VertexLogs) Tail(v digest.Digest) []string {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	lines := append([]string{}, l.lines[v]...)
+	if partial := l.partial[v]; len(partial) > 0 {
+		lines = append(lines, string(partial))
+		if len(lines) > l.max {
+			lines = lines[len(lines)-l.max:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,27 @@
+package solver
+
+import (
+	"testing"
+
+	bk "github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVertexLogs(t *testing.T) {
+	logs := NewVertexLogs(3)
+	a := digest.FromString("a")
+	b := digest.FromString("b")
+
+	logs.Add(&bk.SolveStatus{
+		Logs: []*bk.VertexLog{
+			{Vertex: a, Data: []byte("one\ntwo\nth")},
+			{Vertex: b, Data: []byte("other\n")},
+			{Vertex: a, Data: []byte("ree\r\nfour\nfi")},
+		},
+	})
+
+	require.Equal(t, []string{"three", "four", "fi"}, logs.Tail(a))
+	require.Equal(t, []string{"other"}, logs.Tail(b))
+	require.Empty(t, logs.Tail(digest.FromString("c")))
+}
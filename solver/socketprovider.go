@@ -3,6 +3,7 @@ package solver
 import (
 	"context"
 	"fmt"
+	"net"
 
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/session/sshforward"
@@ -41,9 +42,23 @@ func (sp *SocketProvider) ForwardAgent(stream sshforward.SSH_ForwardAgentServer)
 		return fmt.Errorf("invalid socket id %q", id)
 	}
 
-	conn, err := dialService(service)
-	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", id, err)
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if service.Listening() {
+		// Wait for the client to connect, then hand the connection over to
+		// the container side of the socket
+		conn, err = service.Accept(stream.Context())
+		if err != nil {
+			return fmt.Errorf("failed to accept connection on %s: %w", id, err)
+		}
+	} else {
+		conn, err = dialService(service)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", id, err)
+		}
 	}
 	defer conn.Close()
 
@@ -12,9 +12,12 @@ import (
 )
 
 func dialService(service *plancontext.Service) (net.Conn, error) {
-	if service.Unix() == "" {
+	switch {
+	case service.Unix() != "":
+		return net.DialTimeout("unix", service.Unix(), time.Second)
+	case service.TCP() != "":
+		return net.DialTimeout("tcp", service.TCP(), time.Second)
+	default:
 		return nil, errors.New("unsupported socket type")
 	}
-
-	return net.DialTimeout("unix", service.Unix(), time.Second)
 }
@@ -13,10 +13,14 @@ import (
 )
 
 func dialService(service *plancontext.Service) (net.Conn, error) {
-	if service.NPipe() == "" {
+	dur := time.Second
+
+	switch {
+	case service.NPipe() != "":
+		return winio.DialPipe(service.NPipe(), &dur)
+	case service.TCP() != "":
+		return net.DialTimeout("tcp", service.TCP(), dur)
+	default:
 		return nil, errors.New("unsupported socket type")
 	}
-
-	dur := time.Second
-	return winio.DialPipe(service.NPipe(), &dur)
 }
@@ -3,9 +3,8 @@ package solver
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"strings"
+	"io"
 	"sync"
 
 	bk "github.com/moby/buildkit/client"
@@ -25,6 +24,8 @@ type Solver struct {
 	opts     Opts
 	eventsWg *sync.WaitGroup
 	closeCh  chan *bk.SolveStatus
+	closeMu  *sync.RWMutex
+	dumpSeq  *int64
 }
 
 type Opts struct {
@@ -34,12 +35,20 @@ type Opts struct {
 	Context *plancontext.Context
 	Auth    *RegistryAuthProvider
 	NoCache bool
+
+	// Output of the vertices, reported when a command fails
+	Logs *VertexLogs
+
+	// Directory where solved definitions are dumped, if set
+	DumpDir string
 }
 
 func New(opts Opts) Solver {
 	return Solver{
 		eventsWg: &sync.WaitGroup{},
 		closeCh:  make(chan *bk.SolveStatus),
+		closeMu:  &sync.RWMutex{},
+		dumpSeq:  new(int64),
 		opts:     opts,
 	}
 }
@@ -72,11 +81,23 @@ func (s Solver) NoCache() bool {
 }
 
 func (s Solver) Stop() {
+	// No export can start forwarding events once the channel is closed
+	s.closeMu.Lock()
 	close(s.closeCh)
+	s.closeMu.Unlock()
+
 	s.eventsWg.Wait()
 	close(s.opts.Events)
 }
 
+// Output returns the last lines of output of a vertex
+func (s Solver) Output(v digest.Digest) []string {
+	if s.opts.Logs == nil {
+		return nil
+	}
+	return s.opts.Logs.Tail(v)
+}
+
 func (s Solver) AddCredentials(target, username, secret string) {
 	s.opts.Auth.AddCredentials(target, username, secret)
 }
@@ -150,6 +171,10 @@ func (s Solver) Solve(ctx context.Context, st llb.State, platform specs.Platform
 		RawJSON("llb", jsonLLB).
 		Msg("solving")
 
+	if err := s.dump(ctx, def, platform); err != nil {
+		return nil, err
+	}
+
 	// call solve
 	res, err := s.SolveRequest(ctx, bkgw.SolveRequest{
 		Definition: def,
@@ -161,11 +186,31 @@ func (s Solver) Solve(ctx context.Context, st llb.State, platform specs.Platform
 	return res.SingleRef()
 }
 
+// SolveDefinition solves a marshaled definition, e.g. from a dump
+func (s Solver) SolveDefinition(ctx context.Context, def *bkpb.Definition) (bkgw.Reference, error) {
+	if s.opts.NoCache {
+		var d llb.Definition
+		d.FromPB(def)
+		if err := invalidateCache(&d); err != nil {
+			return nil, err
+		}
+		def = d.ToPB()
+	}
+
+	res, err := s.SolveRequest(ctx, bkgw.SolveRequest{
+		Definition: def,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.SingleRef()
+}
+
 // Forward events from solver to the main events channel
-// It creates a task in the solver waiting group to be
+// The caller adds a task in the solver waiting group to be
 // sure that everything will be forward to the main channel
 func (s Solver) forwardEvents(ch chan *bk.SolveStatus) {
-	s.eventsWg.Add(1)
 	defer s.eventsWg.Done()
 
 	for event := range ch {
@@ -174,19 +219,48 @@ func (s Solver) forwardEvents(ch chan *bk.SolveStatus) {
 }
 
 // Export will export `st` to `output`
-// FIXME: this is currently implemented as a hack, starting a new Build session
-// within buildkit from the Control API. Ideally the Gateway API should allow to
-// Export directly.
+// Local and tar exports are written by the client, reading the files through
+// the gateway session. This saves starting a second Build per export, but
+// costs a round trip per directory and per chunk of file, so trees of many
+// small files may be slower than with the local exporter (see
+// BenchmarkExport).
+// Image and OCI exports, including pushes, are not run in the gateway session:
+// they need buildkit's exporters, which only run at the end of a Build.
+// FIXME: they're currently implemented as a hack, starting a new Build session
+// within buildkit from the Control API. Ideally the Gateway API should allow
+// to Export directly.
 func (s Solver) Export(ctx context.Context, st llb.State, img *dockerfile2llb.Image, output bk.ExportEntry, platform specs.Platform) (*bk.SolveResponse, error) {
-	// Check close event channel and return if we're already done with the main pipeline
+	switch output.Type {
+	case bk.ExporterLocal, bk.ExporterTar:
+		return s.exportSession(ctx, st, output, platform)
+	}
+
+	// Check close event channel and return if we're already done with the main
+	// pipeline. The events of this build are accounted for before Stop() can
+	// close the main events channel.
+	s.closeMu.RLock()
 	select {
 	case <-s.closeCh:
+		s.closeMu.RUnlock()
 		return nil, context.Canceled
 	default:
 	}
+	s.eventsWg.Add(1)
+	s.closeMu.RUnlock()
+
+	ch := make(chan *bk.SolveStatus)
+
+	// Forward this build session events to the main events channel, for logging
+	// purposes.
+	go s.forwardEvents(ch)
 
 	def, err := s.Marshal(ctx, st, llb.Platform(platform))
+	if err == nil {
+		err = s.dump(ctx, def, platform)
+	}
 	if err != nil {
+		// Build() closes the channel otherwise
+		close(ch)
 		return nil, err
 	}
 
@@ -199,12 +273,6 @@ func (s Solver) Export(ctx context.Context, st llb.State, img *dockerfile2llb.Im
 		},
 	}
 
-	ch := make(chan *bk.SolveStatus)
-
-	// Forward this build session events to the main events channel, for logging
-	// purposes.
-	go s.forwardEvents(ch)
-
 	return s.opts.Control.Build(ctx, opts, "", func(ctx context.Context, c bkgw.Client) (*bkgw.Result, error) {
 		res, err := c.Solve(ctx, bkgw.SolveRequest{
 			Definition: def,
@@ -227,6 +295,36 @@ func (s Solver) Export(ctx context.Context, st llb.State, img *dockerfile2llb.Im
 	}, ch)
 }
 
+// exportSession solves `st` and exports it with the client's own exporters, in
+// the main session
+func (s Solver) exportSession(ctx context.Context, st llb.State, output bk.ExportEntry, platform specs.Platform) (*bk.SolveResponse, error) {
+	ref, err := s.Solve(ctx, st, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	switch output.Type {
+	case bk.ExporterLocal:
+		err = s.exportLocal(ctx, ref, output.OutputDir)
+	case bk.ExporterTar:
+		var w io.WriteCloser
+		if w, err = output.Output(output.Attrs); err != nil {
+			return nil, err
+		}
+		err = s.exportTar(ctx, ref, w)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to export to client: %w", err)
+	}
+
+	return &bk.SolveResponse{
+		ExporterResponse: map[string]string{},
+	}, nil
+}
+
 type llbOp struct {
 	Op         bkpb.Op
 	Digest     digest.Digest
@@ -246,22 +344,3 @@ func dumpLLB(def *bkpb.Definition) ([]byte, error) {
 	}
 	return json.Marshal(ops)
 }
-
-// A helper to remove noise from buildkit error messages.
-// FIXME: Obviously a cleaner solution would be nice.
-func CleanError(err error) error {
-	noise := []string{
-		"executor failed running ",
-		"buildkit-runc did not terminate successfully",
-		"rpc error: code = Unknown desc = ",
-		"failed to solve: ",
-	}
-
-	msg := err.Error()
-
-	for _, s := range noise {
-		msg = strings.ReplaceAll(msg, s, "")
-	}
-
-	return errors.New(msg)
-}
@@ -0,0 +1,45 @@
+setup() {
+	load 'helpers'
+
+	common_setup
+}
+
+@test "cache du" {
+	cd "$TESTDIR"/tasks/exec
+	"$DAGGER" "do" -p ./mount_cache.cue test
+
+	run "$DAGGER" cache du --type cachemount --format json
+	assert_success
+	assert_output --partial '"type": "cachemount"'
+	refute_output --partial '"type": "layer"'
+
+	run "$DAGGER" cache du --id mycache --format json
+	assert_success
+	assert_output --partial '"id": "mycache"'
+	refute_output --partial '"size": 0'
+
+	run "$DAGGER" cache du --type foo
+	assert_failure
+	assert_output --partial 'unknown cache type "foo"'
+}
+
+@test "cache prune" {
+	cd "$TESTDIR"/tasks/exec
+	"$DAGGER" "do" -p ./mount_cache.cue test
+
+	run "$DAGGER" cache prune --id mycache
+	assert_success
+	assert_output --partial "cleared cache directories"
+
+	run "$DAGGER" cache du --id mycache --format json
+	assert_success
+	assert_output --partial '"size": 0'
+
+	run "$DAGGER" cache prune --type layer --older-than 720h --keep-storage 10GB
+	assert_success
+	assert_output --partial "Total:"
+
+	run "$DAGGER" cache prune --keep-storage lots
+	assert_failure
+	assert_output --partial "invalid --keep-storage"
+}
@@ -0,0 +1,57 @@
+setup() {
+	load 'helpers'
+
+	common_setup
+}
+
+@test "engine status" {
+	run "$DAGGER" engine status --format json
+	assert_success
+	assert_output --partial '"container": "dagger-buildkitd"'
+	assert_output --partial '"volume": "dagger-buildkitd"'
+
+	run "$DAGGER" engine status --format yaml
+	assert_failure
+	assert_output --partial 'invalid format "yaml"'
+}
+
+@test "engine start" {
+	# Starting an already running daemon is a no-op
+	run "$DAGGER" engine start --format json
+	assert_success
+	assert_output --partial '"running": true'
+	assert_output --partial '"upToDate": true'
+
+	run "$DAGGER" engine logs --tail 10
+	assert_success
+}
+
+@test "engine driver" {
+	run "$DAGGER" engine status --engine-driver foo
+	assert_failure
+	assert_output --partial 'unknown engine driver "foo"'
+
+	DAGGER_ENGINE_DRIVER=foo run "$DAGGER" engine status
+	assert_failure
+	assert_output --partial 'unknown engine driver "foo"'
+
+	run "$DAGGER" engine status --engine-driver docker --format json
+	assert_success
+	assert_output --partial '"driver": "docker"'
+	assert_output --partial '"host": "docker-container://dagger-buildkitd"'
+}
+
+@test "engine config" {
+	TEMPDIR=$(mktemp -d)
+	printf 'keepStorage: lots\n' > "$TEMPDIR/engine.yaml"
+
+	run "$DAGGER" engine status --engine-config "$TEMPDIR/engine.yaml"
+	assert_failure
+	assert_output --partial "invalid keepStorage"
+
+	printf 'keepStorage: 10GB\n' > "$TEMPDIR/engine.yaml"
+	run "$DAGGER" engine status --engine-config "$TEMPDIR/engine.yaml" --format json
+	assert_success
+	assert_output --partial '"expectedConfigHash"'
+	assert_output --partial '"upToDate": false'
+}
@@ -39,6 +39,129 @@ setup() {
   rm -f ./test_do
 }
 
+@test "plan/do: only run actions affected by changed files" {
+  rm -f ./plan/do/changed_since/a/new ./plan/do/changed_since/b/new.log
+
+  # untracked files are changed
+  echo -n new > ./plan/do/changed_since/a/new
+  # excluded files are ignored
+  echo -n new > ./plan/do/changed_since/b/new.log
+
+  run "$DAGGER" "do" -p ./plan/do/changed_since.cue --changed-since HEAD test
+  assert_success
+  assert_output --partial "actions.test.a |"
+  refute_output --partial "actions.test.b |"
+  assert_output --partial "skipped: not affected by changes"
+  assert_output --partial "action=actions.test.b"
+
+  # changes to the plan affect all actions
+  cp ./plan/do/changed_since.cue ./plan/do/changed_since_new.cue
+  run "$DAGGER" "do" -p ./plan/do/changed_since_new.cue --changed-since HEAD test
+  rm -f ./plan/do/changed_since_new.cue
+  assert_success
+  assert_output --partial "plan changed since HEAD, running all actions"
+  assert_output --partial "actions.test.a |"
+  assert_output --partial "actions.test.b |"
+
+  run "$DAGGER" "do" -p ./plan/do/changed_since.cue --changed-since not-a-revision test
+  assert_failure
+  assert_output --partial "failed to list files changed since not-a-revision"
+
+  rm -f ./plan/do/changed_since/a/new ./plan/do/changed_since/b/new.log
+}
+
+@test "plan/do: multiple targets" {
+  run "$DAGGER" "do" -p ./plan/do/do_not_run_unspecified_tasks.cue -t test.two -t dependent
+  assert_success
+  assert_output --partial "actions.test.two.script"
+  assert_output --partial "actions.dependent.one.script"
+  # dependency of actions.dependent
+  assert_output --partial "actions.test.one.script"
+  assert_output --partial 'client.filesystem."./dependent_do".write'
+  refute_output --partial "actions.test.three"
+  refute_output --partial "actions.notMe"
+  rm -f ./test_do ./dependent_do
+
+  # with an action as arguments
+  run "$DAGGER" "do" -p ./plan/do/do_not_run_unspecified_tasks.cue test two -t dependent
+  assert_success
+  assert_output --partial "actions.test.two.script"
+  assert_output --partial "actions.dependent.one.script"
+  refute_output --partial "actions.test.three"
+  rm -f ./test_do ./dependent_do
+
+  # names are used as is, like arguments
+  run "$DAGGER" "do" -p ./plan/do/do_not_run_unspecified_tasks.cue -t dashed-name
+  assert_success
+  assert_output --partial 'actions."dashed-name".script'
+  refute_output --partial "actions.test.two"
+
+  run "$DAGGER" "do" -p ./plan/do/do_not_run_unspecified_tasks.cue -t test.two -t notFound
+  assert_failure
+  assert_output --partial "actions.notFound not found"
+}
+
+@test "plan/do: interrupt" {
+  export TMPDIR="$BATS_TEST_TMPDIR/tmp"
+  mkdir -p "$TMPDIR"
+  out="$BATS_TEST_TMPDIR/out"
+
+  "$DAGGER" "do" -p ./plan/do/interrupt.cue test >"$out" 2>&1 &
+  pid=$!
+
+  # wait for the command to run
+  for _ in $(seq 1 120); do
+    grep -q "actions.test.sleep" "$out" && break
+    sleep 1
+  done
+
+  kill -INT "$pid"
+  run wait "$pid"
+  assert_equal "$status" 130
+
+  run cat "$out"
+  assert_output --partial "canceled"
+  assert_output --partial "interrupted"
+
+  # temporary directories are removed
+  run find "$TMPDIR" -name "dagger-export-*"
+  assert_output ""
+}
+
+@test "plan/do: hooks" {
+  rm -f ./hooks_failure
+
+  run "$DAGGER" "do" -p ./plan/do/hooks.cue succeed
+  assert_success
+  assert_output --partial "actions.succeed.run"
+  assert_output --partial "actions.succeed.finally.cleanup"
+  refute_output --partial "actions.succeed.onFailure.notify"
+
+  run "$DAGGER" "do" -p ./plan/do/hooks.cue fail
+  assert_failure
+  assert_output --partial "actions.fail.run"
+  assert_output --partial "actions.fail.finally.cleanup"
+  assert_output --partial "actions.fail.onFailure.notify"
+  # the failure of the action is reported, not the hooks
+  assert_output --partial "exit code: 3"
+
+  run cat ./hooks_failure
+  assert_output "actions.fail.run: 3"
+  rm -f ./hooks_failure
+
+  # only the targets which failed run their onFailure hooks
+  run "$DAGGER" "do" -p ./plan/do/hooks.cue -t succeed -t fail
+  assert_failure
+  assert_output --partial "actions.succeed.finally.cleanup"
+  assert_output --partial "actions.fail.finally.cleanup"
+  assert_output --partial "actions.fail.onFailure.notify"
+  refute_output --partial "actions.succeed.onFailure.notify"
+
+  run cat ./hooks_failure
+  assert_output "actions.fail.run: 3"
+  rm -f ./hooks_failure
+}
+
 @test "plan/do: nice error message for 0.1.0 projects" {
   run "$DAGGER" "do" -p ./plan/do/error_message_for_0.1_projects.cue
   assert_output --partial "attempting to load a dagger 0.1.0 project."
@@ -58,6 +181,25 @@ setup() {
   "$DAGGER" "do" -p ./plan/hello-europa test
 }
 
+@test "plan/do: dump and replay definitions" {
+  cd "$TESTDIR"
+  dump="$(mktemp -d)"
+
+  run "$DAGGER" "do" -p ./plan/hello-europa --dump-llb "$dump" test
+  assert_success
+
+  file="$(ls "$dump"/*-actions.test._exec.json)"
+  run cat "$file"
+  assert_output --partial '"task": "actions.test._exec"'
+
+  run "$DAGGER" debug solve --output "$dump/out" "$file"
+  assert_success
+  run cat "$dump/out/out.txt"
+  assert_output "Hello Europa"
+
+  rm -rf "$dump"
+}
+
 @test "plan/client/filesystem/read/fs/usage" {
   cd "$TESTDIR/plan/client/filesystem/read/fs"
 
@@ -94,6 +236,36 @@ setup() {
   assert_output --partial 'test.log: no such file or directory'
 }
 
+@test "plan/client/filesystem/read/fs/ignore" {
+  cd "$TESTDIR/plan/client/filesystem/read/fs/ignore"
+
+  rm -rf ./data
+  mkdir -p ./data/sub/gen ./data/.git
+  echo -n test > ./data/test.txt
+  echo -n test > ./data/test.log
+  echo -n test > ./data/test.tmp
+  echo -n keep > ./data/keep.log
+  echo -n test > ./data/sub/gen/test.txt
+  echo -n important > ./data/sub/important.log
+  echo -n test > ./data/Dockerfile
+  echo -n ref > ./data/.git/HEAD
+  echo "*.log" > ./data/.gitignore
+  printf 'gen/\n!important.log\n' > ./data/sub/.gitignore
+  echo "Dockerfile" > ./data/.dockerignore
+
+  "$DAGGER" "do" -p . test valid
+  "$DAGGER" "do" -p . test reincluded
+  "$DAGGER" "do" -p . test gitreincluded
+
+  for excluded in gitignored nested git dockerignored excluded; do
+    run "$DAGGER" "do" -p . test "$excluded"
+    assert_failure
+    assert_output --partial 'no such file or directory'
+  done
+
+  rm -rf ./data
+}
+
 @test "plan/client/filesystem/read/file" {
   cd "$TESTDIR/plan/client/filesystem/read/file"
 
@@ -115,6 +287,57 @@ setup() {
   rm -rf "./out_fs"
 }
 
+@test "plan/client/filesystem/write fs atomic" {
+  cd "$TESTDIR/plan/client/filesystem/write"
+
+  rm -rf "./out_atomic"
+  mkdir -p "./out_atomic"
+  echo -n previous > ./out_atomic/test
+  echo -n stale > ./out_atomic/stale
+
+  "$DAGGER" "do" -p . test atomic
+  assert [ "$(cat ./out_atomic/test)" = "atomic" ]
+  # files missing from the FS are kept unless pruned
+  assert [ "$(cat ./out_atomic/stale)" = "stale" ]
+
+  # no staging directory is left behind
+  run find . -maxdepth 1 -name ".out_atomic.dagger-*"
+  assert_output ""
+
+  rm -rf "./out_atomic"
+}
+
+@test "plan/client/filesystem/write fs prune" {
+  cd "$TESTDIR/plan/client/filesystem/write"
+
+  rm -rf "./out_prune"
+  mkdir -p "./out_prune/sub"
+  echo -n stale > ./out_prune/stale
+  echo -n stale > ./out_prune/sub/stale
+
+  "$DAGGER" "do" -p . test prune
+  assert [ "$(cat ./out_prune/test)" = "prune" ]
+  assert [ ! -e ./out_prune/stale ]
+  assert [ ! -e ./out_prune/sub ]
+
+  rm -rf "./out_prune"
+}
+
+@test "plan/client/filesystem/write fs permissions" {
+  cd "$TESTDIR/plan/client/filesystem/write"
+
+  rm -rf "./out_modes"
+
+  "$DAGGER" "do" -p . test modes
+  assert [ "$(cat ./out_modes/dir/test)" = "modes" ]
+  run ls -ld "./out_modes/dir"
+  assert_output --partial "drwx------"
+  run ls -l "./out_modes/dir/test"
+  assert_output --partial "-rw-------"
+
+  rm -rf "./out_modes"
+}
+
 @test "plan/client/filesystem/write files" {
   cd "$TESTDIR/plan/client/filesystem/write"
 
@@ -140,6 +363,33 @@ setup() {
   rm -rf ./out_files
 }
 
+@test "plan/client/filesystem/write memoize" {
+  cd "$TESTDIR/plan/client/filesystem/write"
+
+  mkdir -p ./out_files
+  rm -f ./out_files/*
+
+  # runs when the file is missing
+  run "$DAGGER" "do" --memoize -p ./ test file
+  assert_success
+  refute_output --partial "memoized=true"
+  assert [ "$(cat ./out_files/test.txt)" = "foobaz" ]
+
+  # skipped when nothing changed
+  run "$DAGGER" "do" --memoize -p ./ test file
+  assert_success
+  assert_output --partial "memoized=true"
+
+  # runs again when the file was modified
+  echo -n modified > ./out_files/test.txt
+  run "$DAGGER" "do" --memoize -p ./ test file
+  assert_success
+  refute_output --partial "memoized=true"
+  assert [ "$(cat ./out_files/test.txt)" = "foobaz" ]
+
+  rm -rf ./out_files
+}
+
 @test "plan/client/filesystem/conflict" {
   cd "$TESTDIR/plan/client/filesystem/conflict"
 
@@ -158,6 +408,23 @@ setup() {
   assert_failure
 }
 
+@test "plan/client/network listen" {
+  cd "$TESTDIR"
+
+  "$DAGGER" "do" -p ./plan/client/network/listen.cue test &
+  pid=$!
+
+  # Retry until dagger listens. Once accepted, the connection is held until
+  # the container connects to the socket.
+  for _ in $(seq 1 60); do
+    output="$(nc localhost 7777 </dev/null)" && [ -n "$output" ] && break
+    sleep 1
+  done
+  wait "$pid"
+
+  assert [ "$output" = "hello from container" ]
+}
+
 @test "plan/client/env usage" {
   cd "${TESTDIR}"
 
@@ -175,6 +442,74 @@ setup() {
   assert_output --regexp "environment variable \"TEST_(STRING|SECRET)\" not set"
 }
 
+@test "plan/client/env default and optional" {
+  cd "${TESTDIR}"
+
+  unset TEST_DEFAULT TEST_OPTIONAL
+
+  "$DAGGER" "do" -p ./plan/client/env/default.cue test default
+  "$DAGGER" "do" -p ./plan/client/env/default.cue test optional
+
+  TEST_DEFAULT="overridden" "$DAGGER" "do" -p ./plan/client/env/default.cue test override
+
+  run env TEST_OPTIONAL="set" "$DAGGER" "do" -p ./plan/client/env/default.cue test optional
+  assert_failure
+}
+
+@test "plan/client/env dotenv" {
+  cd "${TESTDIR}"
+
+  unset TEST_STRING TEST_SECRET
+
+  "$DAGGER" "do" -p ./plan/client/env/dotenv.cue test
+
+  # Environment takes precedence over dotenv files
+  run env TEST_STRING="bar" "$DAGGER" "do" -p ./plan/client/env/dotenv.cue test string
+  assert_failure
+}
+
+@test "plan/client/env redact" {
+  cd "${TESTDIR}"
+
+  export TEST_SECRET="sup3r-s3cret value"
+
+  for format in plain json; do
+    run "$DAGGER" "do" --log-format "$format" --log-level debug -p ./plan/client/env/redact.cue test
+    assert_success
+    assert_output --partial 'plain: ***'
+    assert_output --partial 'base64: ***'
+    assert_output --partial 'client: ***'
+    refute_output --partial "$TEST_SECRET"
+    refute_output --partial "$(echo -n "$TEST_SECRET" | base64)"
+  done
+}
+
+@test "plan/client/env secrets report" {
+  cd "${TESTDIR}"
+
+  # The plan doesn't run: its env isn't needed
+  run "$DAGGER" secrets -p ./plan/client/env/usage.cue test
+  assert_success
+  assert_output --partial "client.env.TEST_SECRET"
+  assert_output --partial "actions.test.secret.mounts.secret.contents"
+  assert_output --partial "mount /run/secrets/test"
+
+  export TEST_STRING="foo"
+  export TEST_SECRET="bar"
+  run "$DAGGER" "do" --secrets-report -p ./plan/client/env/usage.cue test
+  assert_success
+  assert_output --partial "client.env.TEST_SECRET"
+  assert_output --partial "actions.test.secret.mounts.secret.contents"
+  assert_output --partial "mount /run/secrets/test"
+
+  run "$DAGGER" "do" --secrets-report=json -p ./plan/client/env/usage.cue test
+  assert_success
+  assert_output --partial '"path": "client.env.TEST_SECRET"'
+  assert_output --partial '"path": "actions.test.secret.mounts.secret.contents"'
+  assert_output --partial '"mount": "/run/secrets/test"'
+  refute_output --partial '"bar"'
+}
+
 @test "plan/client/env concrete" {
   cd "${TESTDIR}"
 
@@ -188,11 +523,34 @@ setup() {
 @test "plan/client/commands" {
   cd "${TESTDIR}/plan/client/commands"
 
-  "$DAGGER" "do" -p . test valid
+  run "$DAGGER" "do" -p . test valid
+  assert_success
+  # Output is streamed to the logs, unless captured as a secret
+  assert_output --partial "hello europa"
+  refute_output --partial "hello secretive europa"
 
   run "$DAGGER" "do" -p . test invalid
   assert_failure
   assert_output --partial 'exec: "foobar": executable file not found'
+
+  run "$DAGGER" "do" -p . test failure
+  assert_failure
+  assert_output --partial 'exit status 3'
+}
+
+@test "plan/client/prompt" {
+  cd "${TESTDIR}/plan/client/prompt"
+
+  # Not running in an interactive terminal: defaults are used
+  "$DAGGER" "do" -p . test defaults </dev/null
+
+  run "$DAGGER" "do" -p . test required </dev/null
+  assert_failure
+  assert_output --partial 'cannot prompt for "One-time password": not running in an interactive terminal'
+
+  run "$DAGGER" "do" -p . test invalid </dev/null
+  assert_failure
+  assert_output --partial 'cannot prompt for "Region": default "asia" is not one of the options ["eu" "us"]'
 }
 
 @test "plan/with" {
@@ -209,6 +567,50 @@ setup() {
   assert_output --partial "actions.test.params.env.FOO: non-concrete value string"
 }
 
+@test "plan/cache" {
+  cd "$TESTDIR/plan/cache"
+  rm -rf ./cache ./override
+
+  # Cache exported to the directory declared in the plan
+  run "$DAGGER" "do" -p ./local.cue test
+  assert_success
+  test -f ./cache/index.json
+
+  # Flags override the plan
+  run "$DAGGER" "do" -p ./local.cue --cache-to type=local,dest=./override test
+  assert_success
+  test -f ./override/index.json
+
+  run "$DAGGER" "do" -p ./invalid.cue test
+  assert_failure
+  assert_output --partial "cache.to.0.ref"
+
+  rm -rf ./cache ./override
+}
+
+@test "plan/concurrency" {
+  cd "$TESTDIR/plan/concurrency"
+
+  # Limits declared in the plan
+  run "$DAGGER" "do" -p ./groups.cue test
+  assert_success
+  assert_output --partial "queued"
+  assert_output --partial "actions.test.one"
+  assert_output --partial "actions.test.two"
+
+  run "$DAGGER" "do" -p ./groups.cue dashed-test
+  assert_success
+
+  # Limit of the command line
+  run "$DAGGER" "do" -p ./parallel.cue --parallel 1 test
+  assert_success
+  assert_output --partial "queued"
+
+  run "$DAGGER" "do" -p ./invalid.cue test
+  assert_failure
+  assert_output --partial "concurrency.max"
+}
+
 @test "plan/platform" {
    cd "$TESTDIR"
 
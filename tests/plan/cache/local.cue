@@ -0,0 +1,17 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	cache: {
+		from: [{type: "local", path: "./cache"}]
+		to: [{type: "local", path: "./cache", mode: "max"}, {type: "inline"}]
+	}
+
+	actions: test: core.#Pull & {
+		source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+	}
+}
@@ -27,6 +27,29 @@ dagger.#Plan & {
 			stderr: string
 		}
 		invalid: name: "foobar"
+		dir: {
+			name: "cat"
+			args: ["./commands/test.txt"]
+			dir: ".."
+		}
+		env: {
+			name: "sh"
+			flags: "-c": "echo -n $TEST_STRING $TEST_SECRET"
+			env: {
+				TEST_STRING: "hello"
+				TEST_SECRET: secret.stdout
+			}
+			stdout: dagger.#Secret
+		}
+		exit: {
+			name: "sh"
+			flags: "-c": "exit 3"
+			exit: int
+		}
+		failure: {
+			name: "sh"
+			flags: "-c": "exit 3"
+		}
 	}
 	actions: {
 		image: core.#Pull & {
@@ -37,6 +60,10 @@ dagger.#Plan & {
 				input: image.output
 				args: ["echo", client.commands.invalid.stdout]
 			}
+			failure: core.#Exec & {
+				input: image.output
+				args: ["echo", client.commands.failure.stdout]
+			}
 			valid: {
 				normal: core.#Exec & {
 					input: image.output
@@ -50,6 +77,27 @@ dagger.#Plan & {
 					input: image.output
 					args: ["test", strings.TrimSpace(client.commands.error.stderr), "=", "error"]
 				}
+				dir: core.#Exec & {
+					input: image.output
+					args: ["test", strings.TrimSpace(client.commands.dir.stdout), "=", "test"]
+				}
+				exit: core.#Exec & {
+					input: image.output
+					args: ["test", "\(client.commands.exit.exit)", "=", "3"]
+				}
+				env: core.#Exec & {
+					input: image.output
+					mounts: secret: {
+						dest:     "/run/secrets/test"
+						contents: client.commands.env.stdout
+					}
+					args: [
+						"sh", "-c",
+						#"""
+						test "$(cat /run/secrets/test)" = "hello hello secretive europa"
+						"""#,
+					]
+				}
 				secret: core.#Exec & {
 					input: image.output
 					mounts: secret: {
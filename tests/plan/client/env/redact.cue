@@ -0,0 +1,39 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: {
+		env: TEST_SECRET: dagger.#Secret
+		commands: leak: {
+			name: "sh"
+			args: ["-c", "echo \"client: $TEST_SECRET\" >&2"]
+			env: TEST_SECRET: client.env.TEST_SECRET
+		}
+	}
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+		test: {
+			exec: core.#Exec & {
+				input: image.output
+				always: true
+				env: TEST_SECRET: client.env.TEST_SECRET
+				args: [
+					"sh", "-c",
+					#"""
+						echo "plain: $TEST_SECRET"
+						echo "base64: $(echo -n "$TEST_SECRET" | base64)"
+						"""#,
+				]
+			}
+			command: core.#Nop & {
+				input: client.commands.leak.stderr
+			}
+		}
+	}
+}
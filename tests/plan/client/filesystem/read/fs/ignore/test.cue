@@ -0,0 +1,38 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	// The directory is created by the test, so that its ignore files
+	// don't apply to the repository
+	client: filesystem: "./data": read: {
+		contents: dagger.#FS
+		ignore: [".gitignore", ".dockerignore"]
+		exclude: ["*.tmp", "!keep.log"]
+	}
+	actions: test: {
+		[string]: core.#ReadFile & {
+			input: client.filesystem."./data".read.contents
+		}
+		valid: {
+			path:     "test.txt"
+			contents: "test"
+		}
+		reincluded: {
+			path:     "keep.log"
+			contents: "keep"
+		}
+		gitreincluded: {
+			path:     "sub/important.log"
+			contents: "important"
+		}
+		gitignored: path:    "test.log"
+		nested: path:        "sub/gen/test.txt"
+		git: path:           ".git/HEAD"
+		dockerignored: path: "Dockerfile"
+		excluded: path:      "test.tmp"
+	}
+}
@@ -8,6 +8,21 @@ import (
 dagger.#Plan & {
 	client: filesystem: {
 		out_fs: write: contents:               actions.test.fs.data.output
+		out_atomic: write: {
+			contents: actions.test.atomic.data.output
+			atomic:   true
+		}
+		out_prune: write: {
+			contents: actions.test.prune.data.output
+			prune:    true
+		}
+		out_modes: write: {
+			contents: actions.test.modes.data.output
+			permissions: {
+				files: 0o600
+				dirs:  0o700
+			}
+		}
 		"out_files/test.txt": write: contents: actions.test.file.data.contents
 		"out_files/secret.txt": write: {
 			contents:    actions.test.secret.data.output
@@ -25,6 +40,21 @@ dagger.#Plan & {
 				path:     "/test"
 				contents: "foobar"
 			}
+			atomic: data: core.#WriteFile & {
+				input:    dagger.#Scratch
+				path:     "/test"
+				contents: "atomic"
+			}
+			prune: data: core.#WriteFile & {
+				input:    dagger.#Scratch
+				path:     "/test"
+				contents: "prune"
+			}
+			modes: data: core.#WriteFile & {
+				input:    dagger.#Scratch
+				path:     "/dir/test"
+				contents: "modes"
+			}
 			file: {
 				// Only using contents for reference in client
 				data: core.#WriteFile & {
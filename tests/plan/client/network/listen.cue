@@ -0,0 +1,31 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: network: "tcp://localhost:7777": listen: dagger.#Socket
+
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		imageWithSocat: core.#Exec & {
+			input: image.output
+			args: ["apk", "add", "--no-cache", "socat"]
+		}
+
+		test: core.#Exec & {
+			input: imageWithSocat.output
+			always: true
+			mounts: listen: {
+				dest:     "/run/listen.sock"
+				contents: client.network."tcp://localhost:7777".listen
+			}
+			args: ["sh", "-c", "echo -n hello from container | socat - UNIX-CONNECT:/run/listen.sock"]
+		}
+	}
+}
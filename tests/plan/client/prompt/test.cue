@@ -0,0 +1,80 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: prompt: {
+		text: {
+			message: "Release note"
+			value:   string
+			default: "hello europa"
+		}
+		choice: {
+			message: "Environment"
+			value:   string
+			options: ["staging", "production"]
+			default: "staging"
+		}
+		confirm: {
+			message: "Deploy"
+			value:   bool
+			default: true
+		}
+		password: {
+			message: "Password"
+			value:   dagger.#Secret
+			default: "hello secretive europa"
+		}
+		required: {
+			message: "One-time password"
+			value:   string
+		}
+		invalid: {
+			message: "Region"
+			value:   string
+			options: ["eu", "us"]
+			default: "asia"
+		}
+	}
+
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+		test: {
+			defaults: {
+				text: core.#Exec & {
+					input: image.output
+					args: ["test", client.prompt.text.value, "=", "hello europa"]
+				}
+				choice: core.#Exec & {
+					input: image.output
+					args: ["test", client.prompt.choice.value, "=", "staging"]
+				}
+				confirm: core.#Exec & {
+					input: image.output
+					args: ["test", "\(client.prompt.confirm.value)", "=", "true"]
+				}
+				password: core.#Exec & {
+					input: image.output
+					mounts: secret: {
+						dest:     "/run/secrets/test"
+						contents: client.prompt.password.value
+					}
+					args: ["sh", "-c", "test \"$(cat /run/secrets/test)\" = \"hello secretive europa\""]
+				}
+			}
+			required: core.#Exec & {
+				input: image.output
+				args: ["echo", client.prompt.required.value]
+			}
+			invalid: core.#Exec & {
+				input: image.output
+				args: ["echo", client.prompt.invalid.value]
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	// At most one #Exec at once
+	concurrency: groups: exec: {
+		limit: 1
+		types: ["Exec"]
+	}
+	// Action names are parsed like `dagger do` arguments
+	concurrency: groups: "dashed-group": {
+		limit: 1
+		actions: ["dashed-test"]
+	}
+
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		test: [name=string]: core.#Exec & {
+			input: image.output
+			args: ["sh", "-c", "sleep 1 && echo \(name)"]
+			always: true
+		}
+		test: {
+			one: _
+			two: _
+		}
+
+		"dashed-test": core.#Exec & {
+			input: image.output
+			args: ["echo", "dashed"]
+			always: true
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"dagger.io/dagger"
+)
+
+dagger.#Plan & {
+	concurrency: max: 0
+
+	actions: test: {}
+}
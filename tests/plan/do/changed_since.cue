@@ -0,0 +1,27 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: filesystem: {
+		"./plan/do/changed_since/a": read: contents: dagger.#FS
+		"./plan/do/changed_since/b": read: {
+			contents: dagger.#FS
+			exclude: ["*.log"]
+		}
+	}
+
+	actions: test: {
+		a: core.#ReadFile & {
+			input: client.filesystem."./plan/do/changed_since/a".read.contents
+			path:  "/file"
+		}
+		b: core.#ReadFile & {
+			input: client.filesystem."./plan/do/changed_since/b".read.contents
+			path:  "/file"
+		}
+	}
+}
@@ -44,6 +44,11 @@ dagger.#Plan & {
 			export: files: "/output.txt": string
 		}
 
+		"dashed-name": bash.#Run & {
+			input: image.output
+			script: contents: "true"
+		}
+
 		notMe: bash.#Run & {
 			input: image.output
 			script: contents: "false"
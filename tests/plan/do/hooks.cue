@@ -0,0 +1,60 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: filesystem: "./hooks_failure": write: contents: actions.fail.onFailure.notify.input
+
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		succeed: {
+			run: core.#Exec & {
+				input: image.output
+				args: ["true"]
+			}
+
+			// Doesn't run when only another target failed
+			onFailure: {
+				failure: dagger.#Failure
+
+				notify: core.#Nop & {
+					input: "\(failure.task): \(failure.exitCode)"
+				}
+			}
+
+			finally: cleanup: core.#Exec & {
+				input: image.output
+				args: ["true"]
+				always: true
+			}
+		}
+
+		fail: {
+			run: core.#Exec & {
+				input: image.output
+				args: ["sh", "-c", "echo oops; exit 3"]
+				always: true
+			}
+
+			onFailure: {
+				failure: dagger.#Failure
+
+				notify: core.#Nop & {
+					input: "\(failure.task): \(failure.exitCode)"
+				}
+			}
+
+			finally: cleanup: core.#Exec & {
+				input: image.output
+				args: ["true"]
+				always: true
+			}
+		}
+	}
+}
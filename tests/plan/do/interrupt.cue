@@ -0,0 +1,28 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		test: {
+			sleep: core.#Exec & {
+				input: image.output
+				args: ["sleep", "60"]
+				always: true
+			}
+
+			// Creates a temporary directory before the plan runs
+			export: core.#Export & {
+				input: sleep.output
+				tag:   "interrupt"
+			}
+		}
+	}
+}
@@ -39,6 +39,19 @@ setup() {
 
     "$DAGGER" "do" -p ./user.cue test
     "$DAGGER" "do" -p ./workdir.cue verify
+
+    run "$DAGGER" "do" --log-format plain -p ./fail.cue fail
+    assert_failure
+    assert_output --partial 'task: actions.fail (#Exec) at'
+    assert_output --partial 'fail.cue:14:'
+    assert_output --partial 'command: "sh" "-c"'
+    assert_output --partial 'exit code: 3'
+    assert_output --partial '| something went wrong'
+
+    run "$DAGGER" "do" --log-format json -p ./fail.cue fail
+    assert_failure
+    assert_output --partial '"exitCode":3'
+    assert_output --partial '"type":"Exec"'
 }
 
 @test "task: #Copy" {
@@ -113,6 +126,29 @@ setup() {
     "$DAGGER" "do" -p ./tasks/newsecret/newsecret.cue verify
 }
 
+@test "task: #DecodeSecret" {
+    "$DAGGER" "do" -p ./tasks/decodesecret/formats.cue verify
+
+    run env SECRETS=$'[db]\nhosts = ["a", "b"]\n' "$DAGGER" "do" -p ./tasks/decodesecret/unsupported.cue decode
+    assert_failure
+    assert_output --partial "unsupported value at db.hosts: list values can't be decoded as secrets"
+}
+
+@test "task: #TemplateSecret" {
+    "$DAGGER" "do" -p ./tasks/templatesecret/templatesecret.cue verify
+}
+
+@test "task: #DecryptSecret" {
+    cd ./tasks/decryptsecret
+
+    "$DAGGER" "do" -p ./decryptsecret.cue verify
+
+    # A key which wasn't used to encrypt the file
+    run env AGE_KEY="AGE-SECRET-KEY-1VHF8DXAFD36NN0FRE5K98VRENYDPEJND43D30FK0LXRDWHYDV50Q3LYHW0" "$DAGGER" "do" -p ./decryptsecret_invalid.cue decrypt
+    assert_failure
+    assert_output --partial "could not decrypt secret with the provided keys"
+}
+
 @test "task: #TrimSecret" {
     "$DAGGER" "do" -p ./tasks/trimsecret/trimsecret.cue verify
 }
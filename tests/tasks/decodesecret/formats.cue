@@ -0,0 +1,95 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		generate: core.#Exec & {
+			input: image.output
+			args: [
+				"sh", "-c",
+				#"""
+					mkdir /secrets
+					printf 'TOKEN=dotenv-token\nexport QUOTED="dotenv quoted"\n' > /secrets/dotenv
+					printf 'token = ini-top\n[profile dev]\ntoken = ini-dev\n' > /secrets/ini
+					printf 'token = "toml-top"\nport = 5432\n[db]\npassword = "toml-db"\n' > /secrets/toml
+					printf '{"db.password": "json-db", "hosts": ["a", "b"]}' > /secrets/json
+					"""#,
+			]
+		}
+
+		decode: {
+			for format in ["dotenv", "ini", "toml", "json"] {
+				"\(format)": {
+					load: core.#NewSecret & {
+						input: generate.output
+						path:  "/secrets/\(format)"
+					}
+					secrets: core.#DecodeSecret & {
+						input:    load.output
+						"format": format
+					}
+				}
+			}
+		}
+
+		verify: core.#Exec & {
+			input: image.output
+			mounts: {
+				dotenv: {
+					dest:     "/run/secrets/dotenv"
+					contents: decode.dotenv.secrets.output.TOKEN.contents
+				}
+				dotenvQuoted: {
+					dest:     "/run/secrets/dotenv_quoted"
+					contents: decode.dotenv.secrets.output.QUOTED.contents
+				}
+				ini: {
+					dest:     "/run/secrets/ini"
+					contents: decode.ini.secrets.output.token.contents
+				}
+				iniSection: {
+					dest:     "/run/secrets/ini_section"
+					contents: decode.ini.secrets.output."profile dev".token.contents
+				}
+				toml: {
+					dest:     "/run/secrets/toml"
+					contents: decode.toml.secrets.output.token.contents
+				}
+				tomlPort: {
+					dest:     "/run/secrets/toml_port"
+					contents: decode.toml.secrets.output.port.contents
+				}
+				tomlTable: {
+					dest:     "/run/secrets/toml_table"
+					contents: decode.toml.secrets.output.db.password.contents
+				}
+				// Dotted keys are nested and lists skipped, as they always were
+				json: {
+					dest:     "/run/secrets/json"
+					contents: decode.json.secrets.output.db.password.contents
+				}
+			}
+			args: [
+				"sh", "-c",
+				#"""
+					test "$(cat /run/secrets/dotenv)" = "dotenv-token"
+					test "$(cat /run/secrets/dotenv_quoted)" = "dotenv quoted"
+					test "$(cat /run/secrets/ini)" = "ini-top"
+					test "$(cat /run/secrets/ini_section)" = "ini-dev"
+					test "$(cat /run/secrets/toml)" = "toml-top"
+					test "$(cat /run/secrets/toml_port)" = "5432"
+					test "$(cat /run/secrets/toml_table)" = "toml-db"
+					test "$(cat /run/secrets/json)" = "json-db"
+					"""#,
+			]
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: env: SECRETS: dagger.#Secret
+
+	actions: decode: core.#DecodeSecret & {
+		input:  client.env.SECRETS
+		format: "toml"
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: filesystem: {
+		// Test identity, only used to encrypt secrets.yaml and secrets.json
+		"./keys.txt": read: contents:     dagger.#Secret
+		"./secrets.yaml": read: contents: string
+		"./secrets.json": read: contents: string
+	}
+
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		decrypt: core.#DecryptSecret & {
+			input:  client.filesystem."./secrets.yaml".read.contents
+			format: "yaml"
+			keys: age: client.filesystem."./keys.txt".read.contents
+		}
+
+		decryptJSON: core.#DecryptSecret & {
+			input:  client.filesystem."./secrets.json".read.contents
+			format: "json"
+			keys: age: client.filesystem."./keys.txt".read.contents
+		}
+
+		verify: core.#Exec & {
+			input: image.output
+			mounts: {
+				token: {
+					dest:     "/run/secrets/token"
+					contents: decrypt.output.TOKEN.contents
+				}
+				message: {
+					dest:     "/run/secrets/message"
+					contents: decrypt.output.nested.message.contents
+				}
+				number: {
+					dest:     "/run/secrets/number"
+					contents: decrypt.output.nested.number.contents
+				}
+				label: {
+					dest:     "/run/secrets/label"
+					contents: decrypt.output.nested.label_unencrypted.contents
+				}
+				jsonMessage: {
+					dest:     "/run/secrets/json_message"
+					contents: decryptJSON.output.nested.message.contents
+				}
+				jsonPort: {
+					dest:     "/run/secrets/json_port"
+					contents: decryptJSON.output.nested.port.contents
+				}
+			}
+			args: [
+				"sh", "-c",
+				#"""
+					test "$(cat /run/secrets/token)" = "simple-secret"
+					test "$(cat /run/secrets/message)" = "hello world"
+					test "$(cat /run/secrets/number)" = "42"
+					test "$(cat /run/secrets/label)" = "not secret"
+					test "$(cat /run/secrets/json_message)" = "hello json"
+					test "$(cat /run/secrets/json_port)" = "5432"
+					"""#,
+			]
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	client: {
+		filesystem: "./secrets.yaml": read: contents: string
+		env: AGE_KEY: dagger.#Secret
+	}
+
+	actions: decrypt: core.#DecryptSecret & {
+		input:  client.filesystem."./secrets.yaml".read.contents
+		format: "yaml"
+		keys: age: client.env.AGE_KEY
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"dagger.io/dagger"
+	"dagger.io/dagger/core"
+)
+
+dagger.#Plan & {
+	actions: {
+		image: core.#Pull & {
+			source: "alpine:3.15.0@sha256:e7d88de73db3d3fd9b2d63aa7f447a10fd0220b7cbf39803c803f2af9ba256b3"
+		}
+
+		generate: core.#Exec & {
+			input: image.output
+			args: ["sh", "-c", "echo -n s3cr3t > /token"]
+		}
+
+		token: core.#NewSecret & {
+			input: generate.output
+			path:  "/token"
+		}
+
+		npmrc: core.#TemplateSecret & {
+			template: #"""
+				//{{ .registry }}/:_authToken={{ .token }}
+				//{{ .registry }}/:_auth={{ printf "%s:%s" .user .token | b64enc }}
+				"""#
+			inputs: {
+				registry: "registry.npmjs.org"
+				user:     "dagger"
+				"token":  token.output
+			}
+		}
+
+		verify: core.#Exec & {
+			input: image.output
+			mounts: secret: {
+				dest:     "/run/secrets/npmrc"
+				contents: npmrc.output
+			}
+			args: [
+				"sh", "-c",
+				#"""
+					grep -q "^//registry.npmjs.org/:_authToken=s3cr3t$" /run/secrets/npmrc
+					grep -q "^//registry.npmjs.org/:_auth=$(echo -n dagger:s3cr3t | base64)$" /run/secrets/npmrc
+					"""#,
+			]
+		}
+	}
+}
@@ -10,10 +10,17 @@ import (
 	"github.com/docker/distribution/reference"
 )
 
-func getBuildkitInformation(ctx context.Context) (*BuildkitInformation, error) {
-	formatString := "{{.Config.Image}};{{.State.Running}};{{if index .NetworkSettings.Networks \"host\"}}{{\"true\"}}{{else}}{{\"false\"}}{{end}}"
+func (d *containerDriver) Inspect(ctx context.Context) (*BuildkitInformation, error) {
+	// podman doesn't list the host network in `.NetworkSettings.Networks`
+	hostNetwork := "{{if index .NetworkSettings.Networks \"host\"}}{{\"true\"}}{{else}}{{\"false\"}}{{end}}"
+	if d.cli == "podman" {
+		hostNetwork = "{{if eq .HostConfig.NetworkMode \"host\"}}{{\"true\"}}{{else}}{{\"false\"}}{{end}}"
+	}
+
+	formatString := "{{.Config.Image}};{{.State.Running}};" + hostNetwork + ";{{index .Config.Labels \"" + configLabel + "\"}}"
+	// #nosec
 	cmd := exec.CommandContext(ctx,
-		"docker",
+		d.cli,
 		"inspect",
 		"--format",
 		formatString,
@@ -25,6 +32,9 @@ func getBuildkitInformation(ctx context.Context) (*BuildkitInformation, error) {
 	}
 
 	s := strings.Split(string(output), ";")
+	if len(s) != 4 {
+		return nil, fmt.Errorf("failed to inspect buildkit container: %s", output)
+	}
 
 	// Retrieve the tag
 	ref, err := reference.ParseNormalizedNamed(strings.TrimSpace(s[0]))
@@ -52,6 +62,7 @@ func getBuildkitInformation(ctx context.Context) (*BuildkitInformation, error) {
 		Version:         tag.Tag(),
 		IsActive:        isActive,
 		HaveHostNetwork: haveHostNetwork,
+		ConfigHash:      strings.TrimSpace(s[3]),
 	}, nil
 }
 
@@ -59,4 +70,5 @@ type BuildkitInformation struct {
 	Version         string
 	IsActive        bool
 	HaveHostNetwork bool
+	ConfigHash      string
 }
@@ -4,13 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
 	"runtime/debug"
-	"strings"
 	"time"
 
 	bk "github.com/moby/buildkit/client"
-	_ "github.com/moby/buildkit/client/connhelper/dockercontainer" // import the container connection driver
 	"github.com/rs/zerolog/log"
 )
 
@@ -39,36 +36,54 @@ func init() {
 	}
 }
 
-func Start(ctx context.Context) (string, error) {
+// Start ensures the buildkit daemon is running with the given driver and
+// config, and returns its address. The driver is selected automatically if
+// empty.
+func Start(ctx context.Context, driver string, cfg *Config) (string, error) {
 	if vendoredVersion == "" {
 		return "", fmt.Errorf("vendored version is empty")
 	}
 
-	if err := checkBuildkit(ctx); err != nil {
+	d, err := GetDriver(ctx, driver)
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkBuildkit(ctx, d, cfg); err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("docker-container://%s", containerName), nil
+	return d.Host(), nil
 }
 
 // ensure the buildkit is active and properly set up (e.g. connected to host and last version with moby/buildkit)
-func checkBuildkit(ctx context.Context) error {
-	lg := log.Ctx(ctx)
+func checkBuildkit(ctx context.Context, d Driver, cfg *Config) error {
+	lg := log.Ctx(ctx).With().Str("driver", d.Name()).Logger()
+
+	version, err := d.Version(ctx)
+	if err != nil {
+		return err
+	}
 
-	config, err := getBuildkitInformation(ctx)
+	configHash, err := cfg.Hash()
 	if err != nil {
-		// If that failed, it might be because the docker CLI is out of service.
-		if err := checkDocker(ctx); err != nil {
+		return err
+	}
+
+	config, err := d.Inspect(ctx)
+	if err != nil {
+		// If that failed, it might be because the driver is out of service.
+		if err := d.Available(ctx); err != nil {
 			return err
 		}
 
 		lg.Debug().Msg("no buildkit daemon detected")
 
-		if err := removeBuildkit(ctx); err != nil {
+		if err := d.Remove(ctx); err != nil {
 			lg.Debug().Err(err).Msg("error while removing buildkit")
 		}
 
-		if err := installBuildkit(ctx); err != nil {
+		if err := d.Install(ctx, cfg); err != nil {
 			return err
 		}
 	} else {
@@ -77,29 +92,30 @@ func checkBuildkit(ctx context.Context) error {
 			Str("version", config.Version).
 			Bool("isActive", config.IsActive).
 			Bool("haveHostNetwork", config.HaveHostNetwork).
+			Str("configHash", config.ConfigHash).
 			Msg("detected buildkit config")
 
-		if config.Version != vendoredVersion || !config.HaveHostNetwork {
+		if config.Version != version || !config.HaveHostNetwork || config.ConfigHash != configHash {
 			lg.
 				Info().
-				Str("version", vendoredVersion).
+				Str("version", version).
 				Bool("have host network", config.HaveHostNetwork).
+				Bool("config changed", config.ConfigHash != configHash).
 				Msg("upgrading buildkit")
 
-			if err := removeBuildkit(ctx); err != nil {
+			if err := d.Remove(ctx); err != nil {
 				return err
 			}
-			if err := installBuildkit(ctx); err != nil {
+			if err := d.Install(ctx, cfg); err != nil {
 				return err
 			}
-		}
-		if !config.IsActive {
+		} else if !config.IsActive {
 			lg.
 				Info().
-				Str("version", vendoredVersion).
+				Str("version", version).
 				Msg("starting buildkit")
 
-			if err := startBuildkit(ctx); err != nil {
+			if err := d.Start(ctx); err != nil {
 				return err
 			}
 		}
@@ -108,115 +124,9 @@ func checkBuildkit(ctx context.Context) error {
 	return nil
 }
 
-// ensure the docker CLI is available and properly set up (e.g. permissions to
-// communicate with the daemon, etc)
-func checkDocker(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "docker", "info")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.
-			Ctx(ctx).
-			Error().
-			Err(err).
-			Bytes("output", output).
-			Msg("failed to run docker")
-		return err
-	}
-
-	return nil
-}
-
-// Start the buildkit daemon
-func startBuildkit(ctx context.Context) error {
-	lg := log.
-		Ctx(ctx).
-		With().
-		Str("version", vendoredVersion).
-		Logger()
-
-	lg.Debug().Msg("starting buildkit image")
-
-	cmd := exec.CommandContext(ctx,
-		"docker",
-		"start",
-		containerName,
-	)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		lg.
-			Error().
-			Err(err).
-			Bytes("output", output).
-			Msg("failed to start buildkit container")
-		return err
-	}
-
-	return waitBuildkit(ctx)
-}
-
-// Pull and run the buildkit daemon with a proper configuration
-// If the buildkit daemon is already configured, use startBuildkit
-func installBuildkit(ctx context.Context) error {
-	lg := log.
-		Ctx(ctx).
-		With().
-		Str("version", vendoredVersion).
-		Logger()
-
-	lg.Debug().Msg("pulling buildkit image")
-	// #nosec
-	cmd := exec.CommandContext(ctx,
-		"docker",
-		"pull",
-		image+":"+vendoredVersion,
-	)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		lg.
-			Error().
-			Err(err).
-			Bytes("output", output).
-			Msg("failed to pull buildkit image")
-		return err
-	}
-
-	// FIXME: buildkitd currently runs without network isolation (--net=host)
-	// in order for containers to be able to reach localhost.
-	// This is required for things such as kubectl being able to
-	// reach a KinD/minikube cluster locally
-	// #nosec
-	cmd = exec.CommandContext(ctx,
-		"docker",
-		"run",
-		"--net=host",
-		"-d",
-		"--restart", "always",
-		"-v", volumeName+":/var/lib/buildkit",
-		"--name", containerName,
-		"--privileged",
-		image+":"+vendoredVersion,
-	)
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		// If the daemon failed to start because it's already running,
-		// chances are another dagger instance started it. We can just ignore
-		// the error.
-		if !strings.Contains(string(output), "Error response from daemon: Conflict.") {
-			log.
-				Ctx(ctx).
-				Error().
-				Err(err).
-				Bytes("output", output).
-				Msg("unable to start buildkitd")
-			return err
-		}
-	}
-	return waitBuildkit(ctx)
-}
-
 // waitBuildkit waits for the buildkit daemon to be responsive.
-func waitBuildkit(ctx context.Context) error {
-	c, err := bk.New(ctx, "docker-container://"+containerName)
+func waitBuildkit(ctx context.Context, host string) error {
+	c, err := bk.New(ctx, host)
 	if err != nil {
 		return err
 	}
@@ -239,26 +149,3 @@ func waitBuildkit(ctx context.Context) error {
 	}
 	return errors.New("buildkit failed to respond")
 }
-
-func removeBuildkit(ctx context.Context) error {
-	lg := log.
-		Ctx(ctx)
-
-	cmd := exec.CommandContext(ctx,
-		"docker",
-		"rm",
-		"-fv",
-		containerName,
-	)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		lg.
-			Error().
-			Err(err).
-			Bytes("output", output).
-			Msg("failed to stop buildkit")
-		return err
-	}
-
-	return nil
-}
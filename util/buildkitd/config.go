@@ -0,0 +1,294 @@
+package buildkitd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configLabel is set on buildkit containers to detect config changes
+const configLabel = "io.dagger.buildkitd.config"
+
+// Config of the buildkit daemon, rendered to a `buildkitd.toml`
+type Config struct {
+	// Size of the build cache kept by the garbage collector, e.g. "20GB"
+	KeepStorage string `yaml:"keepStorage,omitempty"`
+
+	// Maximum number of build steps run in parallel
+	MaxParallelism int `yaml:"maxParallelism,omitempty"`
+
+	// Registries configuration, by host (e.g. "docker.io")
+	Registries map[string]RegistryConfig `yaml:"registries,omitempty"`
+
+	// CA certificates trusted for all registries
+	CACerts []string `yaml:"caCerts,omitempty"`
+}
+
+// RegistryConfig configures how the daemon pulls and pushes to a registry
+type RegistryConfig struct {
+	Mirrors []string `yaml:"mirrors,omitempty"`
+
+	// Use plain http, and skip TLS verification for https
+	Insecure bool `yaml:"insecure,omitempty"`
+
+	// CA certificates trusted for this registry
+	CACerts []string `yaml:"caCerts,omitempty"`
+}
+
+// LoadConfig loads and merges config files, in order. Missing files are
+// ignored. Relative certificate paths are relative to their config file.
+func LoadConfig(paths ...string) (*Config, error) {
+	cfg := &Config{}
+
+	for _, p := range paths {
+		p, err := homedir.Expand(p)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		file := &Config{}
+		if err := yaml.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if err := file.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		file.resolve(filepath.Dir(p))
+
+		cfg.merge(file)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.KeepStorage != "" {
+		if _, err := units.RAMInBytes(c.KeepStorage); err != nil {
+			return fmt.Errorf("invalid keepStorage: %w", err)
+		}
+	}
+	if c.MaxParallelism < 0 {
+		return fmt.Errorf("invalid maxParallelism: %d", c.MaxParallelism)
+	}
+	return nil
+}
+
+// resolve makes certificate paths absolute
+func (c *Config) resolve(dir string) {
+	abs := func(paths []string) {
+		for i, p := range paths {
+			if p, err := homedir.Expand(p); err == nil && !filepath.IsAbs(p) {
+				paths[i] = filepath.Join(dir, p)
+			} else if err == nil {
+				paths[i] = p
+			}
+		}
+	}
+
+	abs(c.CACerts)
+	for _, r := range c.Registries {
+		abs(r.CACerts)
+	}
+}
+
+// merge overrides the settings of c with the ones set in o
+func (c *Config) merge(o *Config) {
+	if o.KeepStorage != "" {
+		c.KeepStorage = o.KeepStorage
+	}
+	if o.MaxParallelism != 0 {
+		c.MaxParallelism = o.MaxParallelism
+	}
+	for host, r := range o.Registries {
+		if c.Registries == nil {
+			c.Registries = make(map[string]RegistryConfig)
+		}
+		c.Registries[host] = r
+	}
+	c.CACerts = append(c.CACerts, o.CACerts...)
+}
+
+func (c *Config) empty() bool {
+	return c == nil ||
+		(c.KeepStorage == "" &&
+			c.MaxParallelism == 0 &&
+			len(c.Registries) == 0 &&
+			len(c.CACerts) == 0)
+}
+
+type tomlConfig struct {
+	Worker struct {
+		OCI tomlWorker `toml:"oci"`
+	} `toml:"worker"`
+	Registry map[string]tomlRegistry `toml:"registry,omitempty"`
+}
+
+type tomlWorker struct {
+	GC             bool  `toml:"gc"`
+	GCKeepStorage  int64 `toml:"gckeepstorage,omitempty"`
+	MaxParallelism int   `toml:"max-parallelism,omitempty"`
+}
+
+type tomlRegistry struct {
+	Mirrors  []string `toml:"mirrors,omitempty"`
+	HTTP     bool     `toml:"http,omitempty"`
+	Insecure bool     `toml:"insecure,omitempty"`
+	CA       []string `toml:"ca,omitempty"`
+}
+
+// renderedConfig are the files of a config directory
+type renderedConfig struct {
+	// Contents of the files, by path relative to the config directory
+	files map[string][]byte
+
+	// Global CA certificates, relative to the config directory
+	caCerts []string
+}
+
+// render generates the config files. dir is where the config directory is
+// seen by the daemon.
+func (c *Config) render(dir string) (*renderedConfig, error) {
+	rendered := &renderedConfig{
+		files: make(map[string][]byte),
+	}
+	if c.empty() {
+		return rendered, nil
+	}
+
+	// Copy certificates to the config directory, which may be mounted in a
+	// container
+	addCert := func(src, name string) (string, error) {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return "", fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		rel := path.Join("certs", name+".pem")
+		rendered.files[rel] = data
+		return rel, nil
+	}
+
+	for i, src := range c.CACerts {
+		rel, err := addCert(src, fmt.Sprintf("ca-%d", i))
+		if err != nil {
+			return nil, err
+		}
+		rendered.caCerts = append(rendered.caCerts, rel)
+	}
+
+	cfg := tomlConfig{
+		Registry: make(map[string]tomlRegistry),
+	}
+	cfg.Worker.OCI.GC = true
+	cfg.Worker.OCI.MaxParallelism = c.MaxParallelism
+	if c.KeepStorage != "" {
+		keep, err := units.RAMInBytes(c.KeepStorage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keepStorage: %w", err)
+		}
+		cfg.Worker.OCI.GCKeepStorage = keep
+	}
+
+	for host, r := range c.Registries {
+		reg := tomlRegistry{
+			Mirrors:  r.Mirrors,
+			HTTP:     r.Insecure,
+			Insecure: r.Insecure,
+		}
+
+		// Global certificates are trusted for all registries
+		for _, rel := range rendered.caCerts {
+			reg.CA = append(reg.CA, path.Join(dir, rel))
+		}
+		for i, src := range r.CACerts {
+			name := strings.NewReplacer(":", "-", "/", "-").Replace(host)
+			rel, err := addCert(src, fmt.Sprintf("%s-%d", name, i))
+			if err != nil {
+				return nil, err
+			}
+			reg.CA = append(reg.CA, path.Join(dir, rel))
+		}
+
+		cfg.Registry[host] = reg
+	}
+
+	data, err := toml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rendered.files["buildkitd.toml"] = data
+
+	return rendered, nil
+}
+
+// Hash identifies the config, to detect changes. It's empty for the default
+// config.
+func (c *Config) Hash() (string, error) {
+	rendered, err := c.render("/etc/buildkit")
+	if err != nil {
+		return "", err
+	}
+	return rendered.hash(), nil
+}
+
+func (r *renderedConfig) hash() string {
+	if len(r.files) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(r.files[name]))
+		h.Write(r.files[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// write replaces the contents of dir with the config files, and records the
+// hash of the config
+func (r *renderedConfig) write(dir, hash string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for name, data := range r.files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, data, 0600); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "hash"), []byte(hash), 0600)
+}
+
+// configDir is where the config of a driver is generated
+func configDir(driver string) (string, error) {
+	return homedir.Expand(path.Join(localStateDir, "config", driver))
+}
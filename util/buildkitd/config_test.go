@@ -0,0 +1,64 @@
+package buildkitd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("CERT"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "user.yaml"), []byte(`
+keepStorage: 10GB
+maxParallelism: 2
+registries:
+  docker.io:
+    mirrors: [mirror.gcr.io]
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "project.yaml"), []byte(`
+maxParallelism: 4
+registries:
+  "registry.local:5000":
+    insecure: true
+    caCerts: [ca.pem]
+`), 0600))
+
+	// Missing files are ignored
+	cfg, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+	require.NoError(t, err)
+	hash, err := cfg.Hash()
+	require.NoError(t, err)
+	require.Empty(t, hash)
+
+	cfg, err = LoadConfig(filepath.Join(dir, "user.yaml"), filepath.Join(dir, "project.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "10GB", cfg.KeepStorage)
+	require.Equal(t, 4, cfg.MaxParallelism)
+	require.Len(t, cfg.Registries, 2)
+	require.Equal(t, []string{filepath.Join(dir, "ca.pem")}, cfg.Registries["registry.local:5000"].CACerts)
+
+	rendered, err := cfg.render("/etc/buildkit")
+	require.NoError(t, err)
+	require.Equal(t, []byte("CERT"), rendered.files["certs/registry.local-5000-0.pem"])
+	toml := string(rendered.files["buildkitd.toml"])
+	require.Contains(t, toml, "gckeepstorage = 10737418240")
+	require.Contains(t, toml, "max-parallelism = 4")
+	require.Contains(t, toml, `mirrors = ["mirror.gcr.io"]`)
+	require.Contains(t, toml, `ca = ["/etc/buildkit/certs/registry.local-5000-0.pem"]`)
+
+	// The hash changes with the config, not with where it's rendered
+	hash, err = cfg.Hash()
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+	cfg.MaxParallelism = 8
+	changed, err := cfg.Hash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash, changed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte("keepStorage: lots"), 0600))
+	_, err = LoadConfig(filepath.Join(dir, "invalid.yaml"))
+	require.ErrorContains(t, err, "invalid keepStorage")
+}
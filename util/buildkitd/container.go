@@ -0,0 +1,278 @@
+package buildkitd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "github.com/moby/buildkit/client/connhelper/dockercontainer" // import the container connection driver
+	_ "github.com/moby/buildkit/client/connhelper/podmancontainer" // import the podman connection driver
+	"github.com/rs/zerolog/log"
+)
+
+// containerDriver runs buildkit in a container, using a docker compatible
+// CLI (docker, podman or nerdctl)
+type containerDriver struct {
+	cli string
+}
+
+func newContainerDriver(cli string) *containerDriver {
+	return &containerDriver{cli: cli}
+}
+
+func (d *containerDriver) Name() string {
+	return d.cli
+}
+
+func (d *containerDriver) Host() string {
+	return fmt.Sprintf("%s-container://%s", d.cli, containerName)
+}
+
+func (d *containerDriver) Version(_ context.Context) (string, error) {
+	return vendoredVersion, nil
+}
+
+func (d *containerDriver) Volume() string {
+	return volumeName
+}
+
+// image returns the fully qualified image for CLIs which don't default to
+// the docker hub
+func (d *containerDriver) image() string {
+	if d.cli == "docker" {
+		return image + ":" + vendoredVersion
+	}
+	return "docker.io/" + image + ":" + vendoredVersion
+}
+
+// ensure the CLI is available and properly set up (e.g. permissions to
+// communicate with the daemon, etc)
+func (d *containerDriver) Available(ctx context.Context) error {
+	if _, err := exec.LookPath(d.cli); err != nil {
+		return fmt.Errorf("%w: %s not found in PATH", ErrDriverNotAvailable, d.cli)
+	}
+
+	// #nosec
+	cmd := exec.CommandContext(ctx, d.cli, "info")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.
+			Ctx(ctx).
+			Debug().
+			Err(err).
+			Bytes("output", output).
+			Msgf("failed to run %s", d.cli)
+		return fmt.Errorf("failed to run %s: %w", d.cli, err)
+	}
+
+	return nil
+}
+
+// Start the buildkit daemon
+func (d *containerDriver) Start(ctx context.Context) error {
+	lg := log.
+		Ctx(ctx).
+		With().
+		Str("version", vendoredVersion).
+		Logger()
+
+	lg.Debug().Msg("starting buildkit image")
+
+	// #nosec
+	cmd := exec.CommandContext(ctx,
+		d.cli,
+		"start",
+		containerName,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		lg.
+			Error().
+			Err(err).
+			Bytes("output", output).
+			Msg("failed to start buildkit container")
+		return err
+	}
+
+	return waitBuildkit(ctx, d.Host())
+}
+
+// Pull and run the buildkit daemon with a proper configuration
+// If the buildkit daemon is already configured, use Start
+func (d *containerDriver) Install(ctx context.Context, cfg *Config) error {
+	lg := log.
+		Ctx(ctx).
+		With().
+		Str("version", vendoredVersion).
+		Logger()
+
+	lg.Debug().Msg("pulling buildkit image")
+	// #nosec
+	cmd := exec.CommandContext(ctx,
+		d.cli,
+		"pull",
+		d.image(),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		lg.
+			Error().
+			Err(err).
+			Bytes("output", output).
+			Msg("failed to pull buildkit image")
+		return err
+	}
+
+	configArgs, err := d.configArgs(cfg)
+	if err != nil {
+		return err
+	}
+
+	// FIXME: buildkitd currently runs without network isolation (--net=host)
+	// in order for containers to be able to reach localhost.
+	// This is required for things such as kubectl being able to
+	// reach a KinD/minikube cluster locally
+	args := []string{
+		"run",
+		"--net=host",
+		"-d",
+		"--restart", "always",
+		"-v", volumeName + ":/var/lib/buildkit",
+		"--name", containerName,
+		"--privileged",
+	}
+	args = append(args, configArgs...)
+	args = append(args, d.image())
+
+	// #nosec
+	cmd = exec.CommandContext(ctx, d.cli, args...)
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		// If the daemon failed to start because it's already running,
+		// chances are another dagger instance started it. We can just ignore
+		// the error.
+		if !strings.Contains(string(output), "Error response from daemon: Conflict.") &&
+			!strings.Contains(string(output), "already in use") {
+			log.
+				Ctx(ctx).
+				Error().
+				Err(err).
+				Bytes("output", output).
+				Msg("unable to start buildkitd")
+			return err
+		}
+	}
+	return waitBuildkit(ctx, d.Host())
+}
+
+// configArgs generates the config of the daemon, and returns the arguments
+// to mount it in the container
+func (d *containerDriver) configArgs(cfg *Config) ([]string, error) {
+	hash, err := cfg.Hash()
+	if err != nil || hash == "" {
+		return nil, err
+	}
+
+	dir, err := configDir(d.cli)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := cfg.render("/etc/buildkit")
+	if err != nil {
+		return nil, err
+	}
+	if err := rendered.write(dir, hash); err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"--label", configLabel + "=" + hash,
+		"-v", dir + ":/etc/buildkit:ro",
+	}
+
+	// Also trust global certificates outside of registries (e.g. for git)
+	for i, rel := range rendered.caCerts {
+		args = append(args, "-v", fmt.Sprintf("%s:/etc/ssl/certs/dagger-%d.pem:ro", filepath.Join(dir, filepath.FromSlash(rel)), i))
+	}
+
+	return args, nil
+}
+
+func (d *containerDriver) Stop(ctx context.Context) error {
+	// #nosec
+	cmd := exec.CommandContext(ctx,
+		d.cli,
+		"stop",
+		containerName,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stop buildkit container: %w: %s", err, output)
+	}
+	return nil
+}
+
+func (d *containerDriver) Remove(ctx context.Context) error {
+	lg := log.
+		Ctx(ctx)
+
+	// #nosec
+	cmd := exec.CommandContext(ctx,
+		d.cli,
+		"rm",
+		"-fv",
+		containerName,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		lg.
+			Error().
+			Err(err).
+			Bytes("output", output).
+			Msg("failed to stop buildkit")
+		return err
+	}
+
+	return nil
+}
+
+func (d *containerDriver) Logs(ctx context.Context, w io.Writer, follow bool, tail int) error {
+	args := []string{"logs"}
+	if follow {
+		args = append(args, "--follow")
+	}
+	if tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(tail))
+	}
+	args = append(args, containerName)
+
+	// #nosec
+	cmd := exec.CommandContext(ctx, d.cli, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	return cmd.Run()
+}
+
+func (d *containerDriver) VolumeExists(ctx context.Context) bool {
+	// #nosec
+	cmd := exec.CommandContext(ctx, d.cli, "volume", "inspect", volumeName)
+	return cmd.Run() == nil
+}
+
+func (d *containerDriver) RemoveVolume(ctx context.Context) error {
+	// #nosec
+	cmd := exec.CommandContext(ctx,
+		d.cli,
+		"volume",
+		"rm",
+		volumeName,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove buildkit volume: %w: %s", err, output)
+	}
+	return nil
+}
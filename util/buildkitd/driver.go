@@ -0,0 +1,83 @@
+package buildkitd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDriverNotAvailable is returned by drivers which can't be used on this
+// host, e.g. when their binaries aren't installed
+var ErrDriverNotAvailable = errors.New("engine driver not available")
+
+// Driver runs the buildkit daemon, e.g. in a docker container or as a local
+// process
+type Driver interface {
+	// Name of the driver, as selected with --engine-driver
+	Name() string
+
+	// Available returns an error if the driver can't be used on this host
+	// (e.g. the docker CLI isn't installed or can't reach its daemon),
+	// wrapping ErrDriverNotAvailable if its binaries aren't installed
+	Available(ctx context.Context) error
+
+	// Host is the buildkit address to connect to the daemon
+	Host() string
+
+	// Version of buildkit installed by the driver
+	Version(ctx context.Context) (string, error)
+
+	// Inspect returns an error if the daemon isn't installed
+	Inspect(ctx context.Context) (*BuildkitInformation, error)
+
+	// Install the daemon with the given config, which may be nil
+	Install(ctx context.Context, cfg *Config) error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Remove(ctx context.Context) error
+	Logs(ctx context.Context, w io.Writer, follow bool, tail int) error
+
+	// Volume is where the daemon stores its cache
+	Volume() string
+	VolumeExists(ctx context.Context) bool
+	RemoveVolume(ctx context.Context) error
+}
+
+// drivers, in order of preference when the driver is selected automatically
+var drivers = []Driver{
+	newContainerDriver("docker"),
+	newContainerDriver("podman"),
+	newContainerDriver("nerdctl"),
+	&localDriver{},
+}
+
+// DriverNames returns the names of the supported drivers
+func DriverNames() []string {
+	names := make([]string, 0, len(drivers))
+	for _, d := range drivers {
+		names = append(names, d.Name())
+	}
+	return names
+}
+
+// GetDriver returns the driver called name. If name is empty or "auto", the
+// first available driver is returned.
+func GetDriver(ctx context.Context, name string) (Driver, error) {
+	if name == "" || name == "auto" {
+		for _, d := range drivers {
+			if err := d.Available(ctx); err == nil {
+				return d, nil
+			}
+		}
+		return nil, fmt.Errorf("no engine driver available, tried: %s", strings.Join(DriverNames(), ", "))
+	}
+
+	for _, d := range drivers {
+		if d.Name() == name {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown engine driver %q: must be one of auto, %s", name, strings.Join(DriverNames(), ", "))
+}
@@ -0,0 +1,26 @@
+package buildkitd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriversNotAvailable(t *testing.T) {
+	ctx := context.Background()
+
+	// No engine binary can be found
+	t.Setenv("PATH", t.TempDir())
+
+	for _, d := range drivers {
+		require.ErrorIs(t, d.Available(ctx), ErrDriverNotAvailable, d.Name())
+	}
+
+	// The local driver isn't picked automatically
+	_, err := (&localDriver{}).Inspect(ctx)
+	require.ErrorIs(t, err, ErrDriverNotAvailable)
+
+	_, err = GetDriver(ctx, "auto")
+	require.Error(t, err)
+}
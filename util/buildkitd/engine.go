@@ -0,0 +1,129 @@
+package buildkitd
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Status of the buildkit daemon managed by dagger
+type Status struct {
+	Driver          string `json:"driver"`
+	Container       string `json:"container,omitempty"`
+	Host            string `json:"host"`
+	Exists          bool   `json:"exists"`
+	Running         bool   `json:"running"`
+	Version         string `json:"version,omitempty"`
+	ExpectedVersion string `json:"expectedVersion"`
+	ConfigHash      string `json:"configHash,omitempty"`
+	ExpectedConfig  string `json:"expectedConfigHash,omitempty"`
+	UpToDate        bool   `json:"upToDate"`
+	HostNetwork     bool   `json:"hostNetwork"`
+	Volume          string `json:"volume"`
+	VolumeExists    bool   `json:"volumeExists"`
+}
+
+// GetStatus inspects the buildkit daemon and its cache volume. The daemon is
+// up to date if it runs the expected version, with the given config.
+func GetStatus(ctx context.Context, d Driver, cfg *Config) (*Status, error) {
+	expected, err := d.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	configHash, err := cfg.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Driver:          d.Name(),
+		Host:            d.Host(),
+		ExpectedVersion: expected,
+		ExpectedConfig:  configHash,
+		Volume:          d.Volume(),
+	}
+
+	if _, ok := d.(*containerDriver); ok {
+		status.Container = containerName
+	}
+
+	config, err := d.Inspect(ctx)
+	if err != nil {
+		// Either the daemon doesn't exist, or the driver isn't working
+		if err := d.Available(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		status.Exists = true
+		status.Running = config.IsActive
+		status.Version = config.Version
+		status.HostNetwork = config.HaveHostNetwork
+		status.ConfigHash = config.ConfigHash
+		status.UpToDate = config.Version == expected && config.HaveHostNetwork && config.ConfigHash == configHash
+	}
+
+	status.VolumeExists = d.VolumeExists(ctx)
+
+	return status, nil
+}
+
+// Upgrade re-creates the buildkit daemon with the expected version and
+// config. The cache volume is kept.
+func Upgrade(ctx context.Context, d Driver, cfg *Config) error {
+	if vendoredVersion == "" {
+		return fmt.Errorf("vendored version is empty")
+	}
+
+	status, err := GetStatus(ctx, d, cfg)
+	if err != nil {
+		return err
+	}
+
+	if status.Exists {
+		if err := d.Remove(ctx); err != nil {
+			return err
+		}
+	}
+
+	return d.Install(ctx, cfg)
+}
+
+// Stop the buildkit daemon, if running
+func Stop(ctx context.Context, d Driver) error {
+	status, err := GetStatus(ctx, d, nil)
+	if err != nil {
+		return err
+	}
+	if !status.Running {
+		return nil
+	}
+
+	return d.Stop(ctx)
+}
+
+// Remove the buildkit daemon and, if volume is set, its cache volume
+func Remove(ctx context.Context, d Driver, volume bool) error {
+	status, err := GetStatus(ctx, d, nil)
+	if err != nil {
+		return err
+	}
+
+	if status.Exists {
+		if err := d.Remove(ctx); err != nil {
+			return err
+		}
+	}
+
+	if volume && status.VolumeExists {
+		return d.RemoveVolume(ctx)
+	}
+
+	return nil
+}
+
+// Logs writes the logs of the buildkit daemon to w. If tail is positive,
+// only the last lines are written.
+func Logs(ctx context.Context, d Driver, w io.Writer, follow bool, tail int) error {
+	return d.Logs(ctx, w, follow, tail)
+}
@@ -0,0 +1,310 @@
+package buildkitd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	localStateDir = "~/.config/dagger/buildkitd"
+	localRootDir  = "~/.cache/dagger/buildkitd"
+)
+
+// localDriver spawns buildkitd as a local process, through rootlesskit when
+// not running as root. The binaries must be installed on the host.
+type localDriver struct {
+}
+
+func (d *localDriver) Name() string {
+	return "local"
+}
+
+func (d *localDriver) Host() string {
+	return "unix://" + d.path("buildkitd.sock")
+}
+
+func (d *localDriver) Volume() string {
+	root, err := homedir.Expand(localRootDir)
+	if err != nil {
+		return localRootDir
+	}
+	return root
+}
+
+// path returns the path of a file of the state directory
+func (d *localDriver) path(name string) string {
+	dir, err := homedir.Expand(localStateDir)
+	if err != nil {
+		dir = filepath.Join(os.TempDir(), "dagger-buildkitd")
+	}
+	return filepath.Join(dir, name)
+}
+
+// command returns the command to run buildkitd
+func (d *localDriver) command() []string {
+	cmd := []string{
+		"buildkitd",
+		"--addr", d.Host(),
+		"--root", d.Volume(),
+	}
+	if config, _ := d.config(); config != "" {
+		cmd = append(cmd, "--config", config)
+	}
+	if os.Geteuid() != 0 {
+		cmd = append([]string{"rootlesskit"}, cmd...)
+	}
+	return cmd
+}
+
+func (d *localDriver) Available(_ context.Context) error {
+	if !localSupported {
+		return fmt.Errorf("%w: local buildkitd is not supported on this platform", ErrDriverNotAvailable)
+	}
+	bins := []string{"buildkitd"}
+	if os.Geteuid() != 0 {
+		bins = append(bins, "rootlesskit")
+	}
+	for _, bin := range bins {
+		if _, err := exec.LookPath(bin); err != nil {
+			return fmt.Errorf("%w: %s not found in PATH", ErrDriverNotAvailable, bin)
+		}
+	}
+	return nil
+}
+
+// Version of the installed buildkitd binary, e.g.
+// `buildkitd github.com/moby/buildkit v0.10.0 068cb7c5`
+func (d *localDriver) Version(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, "buildkitd", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get buildkitd version: %w", err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) < 3 {
+		return "", fmt.Errorf("failed to parse buildkitd version: %s", output)
+	}
+	return fields[2], nil
+}
+
+// pid returns the pid of the running daemon, or 0
+func (d *localDriver) pid() int {
+	data, err := os.ReadFile(d.path("buildkitd.pid"))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !processRunning(pid) {
+		return 0
+	}
+	return pid
+}
+
+func (d *localDriver) Inspect(ctx context.Context) (*BuildkitInformation, error) {
+	if err := d.Available(ctx); err != nil {
+		return nil, err
+	}
+
+	pid := d.pid()
+
+	// The binary may have been upgraded since the daemon was started
+	version, err := os.ReadFile(d.path("buildkitd.version"))
+	if err != nil || pid == 0 {
+		v, err := d.Version(ctx)
+		if err != nil {
+			return nil, err
+		}
+		version = []byte(v)
+	}
+
+	_, hash := d.config()
+
+	return &BuildkitInformation{
+		Version:         strings.TrimSpace(string(version)),
+		IsActive:        pid != 0,
+		HaveHostNetwork: true,
+		ConfigHash:      hash,
+	}, nil
+}
+
+// Install generates the config of the daemon and starts it: there is nothing
+// else to install
+func (d *localDriver) Install(ctx context.Context, cfg *Config) error {
+	hash, err := cfg.Hash()
+	if err != nil {
+		return err
+	}
+
+	dir, err := configDir(d.Name())
+	if err != nil {
+		return err
+	}
+
+	rendered, err := cfg.render(dir)
+	if err != nil {
+		return err
+	}
+	if err := rendered.write(dir, hash); err != nil {
+		return err
+	}
+
+	return d.Start(ctx)
+}
+
+// config returns the path and hash of the config generated by Install
+func (d *localDriver) config() (string, string) {
+	dir, err := configDir(d.Name())
+	if err != nil {
+		return "", ""
+	}
+	hash, err := os.ReadFile(filepath.Join(dir, "hash"))
+	if err != nil || len(hash) == 0 {
+		return "", ""
+	}
+	return filepath.Join(dir, "buildkitd.toml"), string(hash)
+}
+
+func (d *localDriver) Start(ctx context.Context) error {
+	if d.pid() != 0 {
+		return waitBuildkit(ctx, d.Host())
+	}
+
+	version, err := d.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.path("buildkitd.pid")), 0700); err != nil {
+		return err
+	}
+	// A stale socket prevents buildkitd from starting
+	if err := os.Remove(d.path("buildkitd.sock")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	logs, err := os.OpenFile(d.path("buildkitd.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	pid, err := spawnProcess(d.command(), logs)
+	if err != nil {
+		return fmt.Errorf("failed to start buildkitd: %w", err)
+	}
+
+	if err := os.WriteFile(d.path("buildkitd.pid"), []byte(strconv.Itoa(pid)), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.path("buildkitd.version"), []byte(version), 0600); err != nil {
+		return err
+	}
+
+	return waitBuildkit(ctx, d.Host())
+}
+
+func (d *localDriver) Stop(ctx context.Context) error {
+	pid := d.pid()
+	if pid == 0 {
+		return nil
+	}
+
+	if err := stopProcess(pid); err != nil {
+		return fmt.Errorf("failed to stop buildkitd: %w", err)
+	}
+
+	// Wait up to 10 seconds for the daemon to exit
+	for retry := 0; retry < 100 && processRunning(pid); retry++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if processRunning(pid) {
+		return fmt.Errorf("buildkitd (pid %d) failed to stop", pid)
+	}
+
+	return os.Remove(d.path("buildkitd.pid"))
+}
+
+func (d *localDriver) Remove(ctx context.Context) error {
+	if err := d.Stop(ctx); err != nil {
+		return err
+	}
+	for _, name := range []string{"buildkitd.pid", "buildkitd.version", "buildkitd.log", "buildkitd.sock"} {
+		if err := os.Remove(d.path(name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *localDriver) Logs(ctx context.Context, w io.Writer, follow bool, tail int) error {
+	f, err := os.Open(d.path("buildkitd.log"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if tail > 0 {
+		// Keep the last lines in a ring buffer
+		lines := make([]string, 0, tail)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if len(lines) == tail {
+				lines = lines[1:]
+			}
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	} else if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+
+	for follow {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(250 * time.Millisecond):
+		}
+		if _, err := io.Copy(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *localDriver) VolumeExists(_ context.Context) bool {
+	_, err := os.Stat(d.Volume())
+	return err == nil
+}
+
+func (d *localDriver) RemoveVolume(ctx context.Context) error {
+	// Files created by a rootless daemon belong to sub-uids: remove them
+	// from its user namespace
+	cmd := []string{"rm", "-rf", d.Volume()}
+	if os.Geteuid() != 0 {
+		cmd = append([]string{"rootlesskit"}, cmd...)
+	}
+
+	// #nosec
+	if output, err := exec.CommandContext(ctx, cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove buildkit cache: %w: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package buildkitd
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const localSupported = true
+
+// spawnProcess starts a detached process, which outlives dagger
+func spawnProcess(args []string, logs *os.File) (int, error) {
+	// #nosec
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+func processRunning(pid int) bool {
+	return syscall.Kill(pid, syscall.Signal(0)) == nil
+}
+
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
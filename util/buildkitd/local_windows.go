@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package buildkitd
+
+import (
+	"errors"
+	"os"
+)
+
+const localSupported = false
+
+var errLocalUnsupported = errors.New("local buildkitd is not supported on windows")
+
+func spawnProcess(_ []string, _ *os.File) (int, error) {
+	return 0, errLocalUnsupported
+}
+
+func processRunning(_ int) bool {
+	return false
+}
+
+func stopProcess(_ int) error {
+	return errLocalUnsupported
+}
@@ -0,0 +1,31 @@
+package buildkitd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+
+	"github.com/docker/cli/cli/connhelper/commandconn"
+	"github.com/moby/buildkit/client/connhelper"
+)
+
+// buildkit doesn't ship a connection helper for nerdctl
+func init() {
+	connhelper.Register("nerdctl-container", nerdctlHelper)
+}
+
+// nerdctlHelper connects to a buildkit daemon running in a nerdctl
+// container, e.g. nerdctl-container://<container>
+func nerdctlHelper(u *url.URL) (*connhelper.ConnectionHelper, error) {
+	container := u.Hostname()
+	if container == "" {
+		return nil, errors.New("url lacks container name")
+	}
+	return &connhelper.ConnectionHelper{
+		ContextDialer: func(ctx context.Context, addr string) (net.Conn, error) {
+			// using background context because context remains active for the duration of the process, after dial has completed
+			return commandconn.New(context.Background(), "nerdctl", "exec", "-i", container, "buildctl", "dial-stdio")
+		},
+	}, nil
+}